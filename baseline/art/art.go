@@ -0,0 +1,465 @@
+// Package art is an ordered map in an adaptive radix tree (ART) for integer keys. The keys are split into 8 bytes,
+// most significant first, and each inner node branches on one byte. Inner nodes grow from Node4 through Node16 and
+// Node48 to Node256 as they get more children, and a chain of nodes with one child is compressed into a prefix of the
+// next node. The depth is bounded by the width of the key, not by the number of keys.
+package art
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const keyLen = 8
+
+type ART[K ~int, V any] struct {
+	// root is nil for an empty tree.
+	root          artNode
+	len           int
+	accessCounter func(n any)
+}
+
+// artNode is either a leaf or one of the inner nodes.
+type artNode interface {
+	countAccess(ac func(n any))
+}
+
+// innerNode is implemented by node4, node16, node48 and node256.
+type innerNode interface {
+	artNode
+	header() *nodeHeader
+	// findChild returns the pointer to the child slot for the byte, or nil if there is no such child.
+	findChild(b byte) *artNode
+	// addChild adds a child for a byte that has no child yet, and returns the node to use from now on, which is a
+	// grown copy if the node was full.
+	addChild(b byte, child artNode) innerNode
+	// forEachChild calls fun for the children in ascending order of their bytes, until fun returns false.
+	forEachChild(fun func(b byte, child artNode) bool) bool
+}
+
+// nodeHeader is the part common for all the inner nodes.
+type nodeHeader struct {
+	// prefix are the bytes of the compressed path, that all the keys in the sub-tree share after the bytes of the
+	// parents.
+	prefix    [keyLen]byte
+	prefixLen uint8
+	nChildren uint16
+}
+
+type leaf[K ~int, V any] struct {
+	key   K
+	value V
+}
+
+type node4 struct {
+	nodeHeader
+	// keys are sorted, and keys[i] is the byte of children[i].
+	keys     [4]byte
+	children [4]artNode
+}
+
+type node16 struct {
+	nodeHeader
+	keys     [16]byte
+	children [16]artNode
+}
+
+type node48 struct {
+	nodeHeader
+	// index[b] is 1 + the position of the child for the byte b in children, or 0 if there is no such child.
+	index    [256]uint8
+	children [48]artNode
+}
+
+type node256 struct {
+	nodeHeader
+	children [256]artNode
+}
+
+////////////////////////////////////////
+// ART functions and methods
+////////////////////////////////////////
+
+func New[K ~int, V any]() *ART[K, V] {
+	return &ART[K, V]{
+		accessCounter: func(n any) {},
+	}
+}
+
+// SetAccessCounter must be called right after New.
+func (a *ART[K, V]) SetAccessCounter(ac func(n any)) {
+	a.accessCounter = ac
+}
+
+func (a *ART[K, V]) Len() int {
+	return a.len
+}
+
+// encodeKey returns the key as bytes that compare in the same order as the keys. The sign bit is flipped so negative
+// keys go before the positive ones.
+func encodeKey[K ~int](key K) [keyLen]byte {
+	var b [keyLen]byte
+	binary.BigEndian.PutUint64(b[:], uint64(key)^(1<<63))
+	return b
+}
+
+func (a *ART[K, V]) Find(key K) (V, bool) {
+	kb := encodeKey(key)
+	n, depth := a.root, 0
+	for n != nil {
+		n.countAccess(a.accessCounter)
+		inner, ok := n.(innerNode)
+		if !ok {
+			if l := n.(*leaf[K, V]); l.key == key {
+				return l.value, true
+			}
+			break
+		}
+		h := inner.header()
+		if h.prefixMismatch(kb, depth) < int(h.prefixLen) {
+			break
+		}
+		depth += int(h.prefixLen)
+		child := inner.findChild(kb[depth])
+		if child == nil {
+			break
+		}
+		n = *child
+		depth++
+	}
+	var zero V
+	return zero, false
+}
+
+// Insert replaces the value if the key is already present.
+func (a *ART[K, V]) Insert(key K, value V) {
+	kb := encodeKey(key)
+	ref, depth := &a.root, 0
+	for {
+		if *ref == nil {
+			*ref = &leaf[K, V]{key: key, value: value}
+			a.len++
+			return
+		}
+		(*ref).countAccess(a.accessCounter)
+		inner, ok := (*ref).(innerNode)
+		if !ok {
+			l := (*ref).(*leaf[K, V])
+			if l.key == key {
+				l.value = value
+				return
+			}
+			// Two keys in place of one, so the leaf becomes a node with the common bytes as the prefix.
+			lb := encodeKey(l.key)
+			n := &node4{}
+			for depth+int(n.prefixLen) < keyLen && lb[depth+int(n.prefixLen)] == kb[depth+int(n.prefixLen)] {
+				n.prefix[n.prefixLen] = kb[depth+int(n.prefixLen)]
+				n.prefixLen++
+			}
+			d := depth + int(n.prefixLen)
+			n.addChild(lb[d], l)
+			n.addChild(kb[d], &leaf[K, V]{key: key, value: value})
+			*ref = n
+			a.len++
+			return
+		}
+		h := inner.header()
+		if p := h.prefixMismatch(kb, depth); p < int(h.prefixLen) {
+			// The key leaves the compressed path, so the path is split at the mismatch by a new node.
+			n := &node4{}
+			copy(n.prefix[:], h.prefix[:p])
+			n.prefixLen = uint8(p)
+			n.addChild(h.prefix[p], inner)
+			n.addChild(kb[depth+p], &leaf[K, V]{key: key, value: value})
+			copy(h.prefix[:], h.prefix[p+1:h.prefixLen])
+			h.prefixLen -= uint8(p + 1)
+			*ref = n
+			a.len++
+			return
+		}
+		depth += int(h.prefixLen)
+		child := inner.findChild(kb[depth])
+		if child == nil {
+			*ref = inner.addChild(kb[depth], &leaf[K, V]{key: key, value: value})
+			a.len++
+			return
+		}
+		ref = child
+		depth++
+	}
+}
+
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false. Sub-trees whose keys are
+// all outside of the range are not visited.
+func (a *ART[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	if a.root == nil || lo >= hi {
+		return
+	}
+	a.scanRec(a.root, [keyLen]byte{}, 0, encodeKey(lo), encodeKey(hi), fun)
+}
+
+// scanRec returns false if fun returned false. path are the bytes of the keys in the sub-tree of n up to the depth.
+func (a *ART[K, V]) scanRec(n artNode, path [keyLen]byte, depth int, lo, hi [keyLen]byte, fun func(key K, value V) bool) bool {
+	n.countAccess(a.accessCounter)
+	inner, ok := n.(innerNode)
+	if !ok {
+		l := n.(*leaf[K, V])
+		if kb := encodeKey(l.key); string(kb[:]) >= string(lo[:]) && string(kb[:]) < string(hi[:]) {
+			return fun(l.key, l.value)
+		}
+		return true
+	}
+	h := inner.header()
+	copy(path[depth:], h.prefix[:h.prefixLen])
+	depth += int(h.prefixLen)
+	return inner.forEachChild(func(b byte, child artNode) bool {
+		path[depth] = b
+		if !overlaps(path, depth+1, lo, hi) {
+			return true
+		}
+		return a.scanRec(child, path, depth+1, lo, hi, fun)
+	})
+}
+
+// overlaps returns true if any key starting with path[:depth] is in [lo, hi) range.
+func overlaps(path [keyLen]byte, depth int, lo, hi [keyLen]byte) bool {
+	minKey, maxKey := path, path
+	for i := depth; i < keyLen; i++ {
+		minKey[i], maxKey[i] = 0, 0xff
+	}
+	return string(maxKey[:]) >= string(lo[:]) && string(minKey[:]) < string(hi[:])
+}
+
+// IntegrityCheck checks that the leaves are in the sub-trees matching their keys, that the children of the nodes are
+// sorted, and that every node is of the smallest kind fitting its children.
+func (a *ART[K, V]) IntegrityCheck() error {
+	if a.root == nil {
+		return nil
+	}
+	count := 0
+	if err := a.integrityCheckRec(a.root, [keyLen]byte{}, 0, &count); err != nil {
+		return err
+	}
+	if count != a.len {
+		return fmt.Errorf("number of leaves %d differs from len %d", count, a.len)
+	}
+	return nil
+}
+
+func (a *ART[K, V]) integrityCheckRec(n artNode, path [keyLen]byte, depth int, count *int) error {
+	inner, ok := n.(innerNode)
+	if !ok {
+		l := n.(*leaf[K, V])
+		if kb := encodeKey(l.key); string(kb[:depth]) != string(path[:depth]) {
+			return fmt.Errorf("leaf key %v does not match path %v", l.key, path[:depth])
+		}
+		*count++
+		return nil
+	}
+	h := inner.header()
+	minChildren, maxChildren := 0, 0
+	switch n.(type) {
+	case *node4:
+		minChildren, maxChildren = 2, 4
+	case *node16:
+		minChildren, maxChildren = 5, 16
+	case *node48:
+		minChildren, maxChildren = 17, 48
+	case *node256:
+		minChildren, maxChildren = 49, 256
+	}
+	if int(h.nChildren) < minChildren || int(h.nChildren) > maxChildren {
+		return fmt.Errorf("%T has %d children, should have from %d to %d", n, h.nChildren, minChildren, maxChildren)
+	}
+	copy(path[depth:], h.prefix[:h.prefixLen])
+	depth += int(h.prefixLen)
+	if depth >= keyLen {
+		return fmt.Errorf("inner node at depth %d, beyond the key length", depth)
+	}
+	var err error
+	prev, nSeen := -1, 0
+	inner.forEachChild(func(b byte, child artNode) bool {
+		if int(b) <= prev {
+			err = fmt.Errorf("children of %T are not sorted", n)
+			return false
+		}
+		prev = int(b)
+		nSeen++
+		path[depth] = b
+		err = a.integrityCheckRec(child, path, depth+1, count)
+		return err == nil
+	})
+	if err == nil && nSeen != int(h.nChildren) {
+		err = fmt.Errorf("%T has %d children, but counts %d", n, nSeen, h.nChildren)
+	}
+	return err
+}
+
+////////////////////////////////////////
+// Inner node functions and methods
+////////////////////////////////////////
+
+func (h *nodeHeader) header() *nodeHeader {
+	return h
+}
+
+// prefixMismatch returns the number of the prefix bytes equal to the key bytes from the depth.
+func (h *nodeHeader) prefixMismatch(kb [keyLen]byte, depth int) int {
+	for i := range int(h.prefixLen) {
+		if h.prefix[i] != kb[depth+i] {
+			return i
+		}
+	}
+	return int(h.prefixLen)
+}
+
+func (n *node4) findChild(b byte) *artNode {
+	for i := range int(n.nChildren) {
+		if n.keys[i] == b {
+			return &n.children[i]
+		}
+	}
+	return nil
+}
+
+func (n *node4) addChild(b byte, child artNode) innerNode {
+	if n.nChildren == 4 {
+		grown := &node16{nodeHeader: n.nodeHeader}
+		copy(grown.keys[:], n.keys[:])
+		copy(grown.children[:], n.children[:])
+		return grown.addChild(b, child)
+	}
+	insertSorted(n.keys[:], n.children[:], int(n.nChildren), b, child)
+	n.nChildren++
+	return n
+}
+
+func (n *node4) forEachChild(fun func(b byte, child artNode) bool) bool {
+	for i := range int(n.nChildren) {
+		if !fun(n.keys[i], n.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node4) countAccess(ac func(n any)) {
+	ac(n)
+}
+
+func (n *node16) findChild(b byte) *artNode {
+	for i := range int(n.nChildren) {
+		if n.keys[i] == b {
+			return &n.children[i]
+		}
+	}
+	return nil
+}
+
+func (n *node16) addChild(b byte, child artNode) innerNode {
+	if n.nChildren == 16 {
+		grown := &node48{nodeHeader: n.nodeHeader}
+		for i := range 16 {
+			grown.index[n.keys[i]] = uint8(i + 1)
+		}
+		copy(grown.children[:], n.children[:])
+		return grown.addChild(b, child)
+	}
+	insertSorted(n.keys[:], n.children[:], int(n.nChildren), b, child)
+	n.nChildren++
+	return n
+}
+
+func (n *node16) forEachChild(fun func(b byte, child artNode) bool) bool {
+	for i := range int(n.nChildren) {
+		if !fun(n.keys[i], n.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node16) countAccess(ac func(n any)) {
+	ac(n)
+}
+
+// insertSorted inserts the byte and the child to the first n entries of the sorted keys and the children.
+func insertSorted(keys []byte, children []artNode, n int, b byte, child artNode) {
+	i := 0
+	for i < n && keys[i] < b {
+		i++
+	}
+	copy(keys[i+1:n+1], keys[i:n])
+	copy(children[i+1:n+1], children[i:n])
+	keys[i], children[i] = b, child
+}
+
+func (n *node48) findChild(b byte) *artNode {
+	if i := n.index[b]; i != 0 {
+		return &n.children[i-1]
+	}
+	return nil
+}
+
+func (n *node48) addChild(b byte, child artNode) innerNode {
+	if n.nChildren == 48 {
+		grown := &node256{nodeHeader: n.nodeHeader}
+		for c, i := range n.index {
+			if i != 0 {
+				grown.children[c] = n.children[i-1]
+			}
+		}
+		return grown.addChild(b, child)
+	}
+	// The children are only added, so the first free position is after the last child.
+	n.children[n.nChildren] = child
+	n.nChildren++
+	n.index[b] = uint8(n.nChildren)
+	return n
+}
+
+func (n *node48) forEachChild(fun func(b byte, child artNode) bool) bool {
+	for c, i := range n.index {
+		if i != 0 && !fun(byte(c), n.children[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node48) countAccess(ac func(n any)) {
+	ac(n)
+}
+
+func (n *node256) findChild(b byte) *artNode {
+	if n.children[b] != nil {
+		return &n.children[b]
+	}
+	return nil
+}
+
+func (n *node256) addChild(b byte, child artNode) innerNode {
+	n.children[b] = child
+	n.nChildren++
+	return n
+}
+
+func (n *node256) forEachChild(fun func(b byte, child artNode) bool) bool {
+	for c, child := range n.children {
+		if child != nil && !fun(byte(c), child) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node256) countAccess(ac func(n any)) {
+	ac(n)
+}
+
+////////////////////////////////////////
+// Leaf functions and methods
+////////////////////////////////////////
+
+func (l *leaf[K, V]) countAccess(ac func(n any)) {
+	ac(l)
+}
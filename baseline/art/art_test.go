@@ -0,0 +1,92 @@
+package art_test
+
+import (
+	"btree-cache-benchmark/baseline/art"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFind(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	sequence := []int{}
+	for i := range 10_000 {
+		sequence = append(sequence, i)
+	}
+	random := []int{}
+	for range 10_000 {
+		random = append(random, r.Int()-r.Int())
+	}
+	for name, values := range map[string][]int{
+		"sequence": sequence,
+		"shuffled": r.Perm(10_000),
+		"random":   random,
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := art.New[int, int]()
+			for _, v := range values {
+				a.Insert(v, v+1)
+			}
+			assert.NoError(t, a.IntegrityCheck())
+			assert.Equal(t, len(values), a.Len())
+			for _, v := range values {
+				actual, ok := a.Find(v)
+				assert.True(t, ok, "value not found for key %d", v)
+				assert.Equal(t, v+1, actual)
+			}
+			a.Insert(values[0], 0)
+			assert.Equal(t, len(values), a.Len())
+			v, _ := a.Find(values[0])
+			assert.Equal(t, 0, v)
+			_, ok := a.Find(-1)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	a := art.New[int, int]()
+	keys := []int{}
+	for range 2000 {
+		k := r.Intn(1<<20) - 1<<19
+		a.Insert(k, k)
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	for range 100 {
+		lo := r.Intn(1<<20) - 1<<19
+		hi := lo + r.Intn(1<<16)
+		expected := []int{}
+		for _, k := range keys {
+			if k >= lo && k < hi {
+				expected = append(expected, k)
+			}
+		}
+		actual := []int{}
+		a.Scan(lo, hi, func(key, value int) bool {
+			actual = append(actual, key)
+			return true
+		})
+		assert.Equal(t, expected, actual, "scan [%d, %d)", lo, hi)
+	}
+}
+
+func TestFindAccessesBoundedByKeyWidth(t *testing.T) {
+	a := art.New[int, int]()
+	for i := range 1 << 16 {
+		a.Insert(i*7919, i)
+	}
+	assert.NoError(t, a.IntegrityCheck())
+	accesses := 0
+	a.SetAccessCounter(func(n any) { accesses++ })
+	for i := range 1 << 16 {
+		accesses = 0
+		a.Find(i * 7919)
+		// Up to one inner node per key byte, and the leaf.
+		assert.LessOrEqual(t, accesses, 9)
+	}
+}
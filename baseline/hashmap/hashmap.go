@@ -0,0 +1,44 @@
+// Package hashmap wraps the built-in Go map, as the baseline for point lookups. It is not ordered, so it has no scans.
+package hashmap
+
+type HashMap[K comparable, V any] struct {
+	m             map[K]V
+	accessCounter func(n any)
+}
+
+func New[K comparable, V any]() *HashMap[K, V] {
+	return &HashMap[K, V]{
+		m:             make(map[K]V),
+		accessCounter: func(n any) {},
+	}
+}
+
+// SetAccessCounter sets the counter called once per operation. The buckets of the Go map are not observable, so the
+// accessed node is identified by the key, as if every entry was a node.
+func (h *HashMap[K, V]) SetAccessCounter(ac func(n any)) {
+	h.accessCounter = ac
+}
+
+func (h *HashMap[K, V]) Len() int {
+	return len(h.m)
+}
+
+func (h *HashMap[K, V]) Find(key K) (V, bool) {
+	h.accessCounter(key)
+	v, ok := h.m[key]
+	return v, ok
+}
+
+func (h *HashMap[K, V]) Insert(key K, value V) {
+	h.accessCounter(key)
+	h.m[key] = value
+}
+
+func (h *HashMap[K, V]) Delete(key K) bool {
+	h.accessCounter(key)
+	if _, ok := h.m[key]; !ok {
+		return false
+	}
+	delete(h.m, key)
+	return true
+}
@@ -0,0 +1,27 @@
+package hashmap_test
+
+import (
+	"btree-cache-benchmark/baseline/hashmap"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFindDelete(t *testing.T) {
+	h := hashmap.New[int, int]()
+	accesses := 0
+	h.SetAccessCounter(func(n any) { accesses++ })
+	for i := range 100 {
+		h.Insert(i, i)
+	}
+	h.Insert(10, 110)
+	assert.Equal(t, 100, h.Len())
+	v, ok := h.Find(10)
+	assert.True(t, ok)
+	assert.Equal(t, 110, v)
+	assert.True(t, h.Delete(10))
+	assert.False(t, h.Delete(10))
+	_, ok = h.Find(10)
+	assert.False(t, ok)
+	assert.Equal(t, 105, accesses)
+}
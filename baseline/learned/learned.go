@@ -0,0 +1,257 @@
+// Package learned is a learned index for integer keys. The keys are kept in a sorted array, and a piecewise-linear
+// model predicts the position of a key in the array within a bounded error, so the lookup only searches the few
+// positions around the prediction. The model is trained for the array it indexes, so inserts go to a small sorted delta
+// buffer, which is merged into the array and the model retrained when it grows too large.
+package learned
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// DefaultEpsilon is the maximal distance between the predicted and the actual position of a key.
+const DefaultEpsilon = 16
+
+// minDeltaSize is the size of the delta buffer below which it is never merged, so a small index is not retrained on
+// every insert.
+const minDeltaSize = 64
+
+type Learned[K ~int, V any] struct {
+	epsilon int
+	// keys and values are the sorted array indexed by the model.
+	keys   []K
+	values []V
+	// segments are sorted by the first key. Each covers the keys from its first key to the first key of the next one.
+	segments []segment[K]
+	// deltaKeys and deltaValues are the sorted inserted pairs that are not in keys yet. A key is never in both.
+	deltaKeys     []K
+	deltaValues   []V
+	accessCounter func(n any)
+}
+
+// segment is a linear model predicting the position of a key as start + slope * (key - firstKey).
+type segment[K ~int] struct {
+	firstKey K
+	start    int
+	slope    float64
+}
+
+func New[K ~int, V any](epsilon int) *Learned[K, V] {
+	return &Learned[K, V]{
+		epsilon:       epsilon,
+		accessCounter: func(n any) {},
+	}
+}
+
+// SetAccessCounter sets the counter called with each probed segment and array element, since the index has no nodes.
+func (l *Learned[K, V]) SetAccessCounter(ac func(n any)) {
+	l.accessCounter = ac
+}
+
+func (l *Learned[K, V]) Len() int {
+	return len(l.keys) + len(l.deltaKeys)
+}
+
+// Segments returns the number of the linear segments of the model.
+func (l *Learned[K, V]) Segments() int {
+	return len(l.segments)
+}
+
+func (l *Learned[K, V]) Find(key K) (V, bool) {
+	if i, found := l.findInArray(key); found {
+		return l.values[i], true
+	}
+	if i := lowerBound(l.deltaKeys, 0, len(l.deltaKeys), key, l.accessCounter); i < len(l.deltaKeys) && l.deltaKeys[i] == key {
+		return l.deltaValues[i], true
+	}
+	var zero V
+	return zero, false
+}
+
+// Insert replaces the value if the key is already present.
+func (l *Learned[K, V]) Insert(key K, value V) {
+	if i, found := l.findInArray(key); found {
+		l.values[i] = value
+		return
+	}
+	i := lowerBound(l.deltaKeys, 0, len(l.deltaKeys), key, l.accessCounter)
+	if i < len(l.deltaKeys) && l.deltaKeys[i] == key {
+		l.deltaValues[i] = value
+		return
+	}
+	l.deltaKeys = slices.Insert(l.deltaKeys, i, key)
+	l.deltaValues = slices.Insert(l.deltaValues, i, value)
+	if len(l.deltaKeys) > max(minDeltaSize, len(l.keys)/8) {
+		l.merge()
+	}
+}
+
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false.
+func (l *Learned[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	i := l.lowerBoundInArray(lo)
+	j := lowerBound(l.deltaKeys, 0, len(l.deltaKeys), lo, l.accessCounter)
+	for {
+		inArray := i < len(l.keys) && l.keys[i] < hi
+		inDelta := j < len(l.deltaKeys) && l.deltaKeys[j] < hi
+		var ok bool
+		switch {
+		case inArray && (!inDelta || l.keys[i] < l.deltaKeys[j]):
+			l.accessCounter(&l.keys[i])
+			ok = fun(l.keys[i], l.values[i])
+			i++
+		case inDelta:
+			l.accessCounter(&l.deltaKeys[j])
+			ok = fun(l.deltaKeys[j], l.deltaValues[j])
+			j++
+		default:
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// IntegrityCheck checks that the keys are sorted and unique, and that the model predicts every key within the error
+// bound.
+func (l *Learned[K, V]) IntegrityCheck() error {
+	if !slices.IsSorted(l.keys) || !slices.IsSorted(l.deltaKeys) {
+		return fmt.Errorf("keys are not sorted")
+	}
+	for i, key := range l.keys {
+		if i > 0 && l.keys[i-1] == key {
+			return fmt.Errorf("duplicate key %v", key)
+		}
+		if p := l.predict(l.segmentFor(key), key); p < i-l.epsilon || p > i+l.epsilon {
+			return fmt.Errorf("key %v at %d predicted at %d, beyond epsilon %d", key, i, p, l.epsilon)
+		}
+	}
+	for _, key := range l.deltaKeys {
+		if _, found := l.findInArray(key); found {
+			return fmt.Errorf("key %v is both in the array and in the delta", key)
+		}
+	}
+	return nil
+}
+
+// findInArray returns the position of the key in the array, and if the key is there.
+func (l *Learned[K, V]) findInArray(key K) (int, bool) {
+	i := l.lowerBoundInArray(key)
+	return i, i < len(l.keys) && l.keys[i] == key
+}
+
+// lowerBoundInArray returns the position of the first key in the array not smaller than the key. The search is bounded
+// by the error of the model around the predicted position.
+func (l *Learned[K, V]) lowerBoundInArray(key K) int {
+	if len(l.keys) == 0 {
+		return 0
+	}
+	s := l.segmentFor(key)
+	if s == -1 {
+		return 0
+	}
+	p := l.predict(s, key)
+	lo, hi := max(p-l.epsilon, 0), min(p+l.epsilon+1, len(l.keys))
+	// The bound holds for the keys in the array. A missing key between two keys can be predicted out of the window of
+	// both of them, so the window is widened until its ends surround the key.
+	for lo > 0 {
+		l.accessCounter(&l.keys[lo-1])
+		if l.keys[lo-1] < key {
+			break
+		}
+		lo = max(lo-l.epsilon-1, 0)
+	}
+	for hi < len(l.keys) {
+		l.accessCounter(&l.keys[hi-1])
+		if l.keys[hi-1] >= key {
+			break
+		}
+		hi = min(hi+l.epsilon+1, len(l.keys))
+	}
+	return lowerBound(l.keys, lo, hi, key, l.accessCounter)
+}
+
+// segmentFor returns the index of the segment covering the key, or -1 if the key is smaller than all the keys.
+func (l *Learned[K, V]) segmentFor(key K) int {
+	lo, hi := 0, len(l.segments)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		l.accessCounter(&l.segments[mid])
+		if l.segments[mid].firstKey <= key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo - 1
+}
+
+// predict returns the predicted position of the key, clamped to the array.
+func (l *Learned[K, V]) predict(s int, key K) int {
+	seg := &l.segments[s]
+	p := seg.start + int(math.Round(seg.slope*keyDistance(seg.firstKey, key)))
+	return min(max(p, 0), len(l.keys)-1)
+}
+
+// keyDistance is key - from for key >= from, without overflow.
+func keyDistance[K ~int](from, key K) float64 {
+	return float64(uint64(key) - uint64(from))
+}
+
+// merge moves the delta into the array and retrains the model.
+func (l *Learned[K, V]) merge() {
+	keys := make([]K, 0, len(l.keys)+len(l.deltaKeys))
+	values := make([]V, 0, len(l.keys)+len(l.deltaKeys))
+	i, j := 0, 0
+	for i < len(l.keys) || j < len(l.deltaKeys) {
+		if j == len(l.deltaKeys) || (i < len(l.keys) && l.keys[i] < l.deltaKeys[j]) {
+			keys, values = append(keys, l.keys[i]), append(values, l.values[i])
+			i++
+		} else {
+			keys, values = append(keys, l.deltaKeys[j]), append(values, l.deltaValues[j])
+			j++
+		}
+	}
+	l.keys, l.values = keys, values
+	l.deltaKeys, l.deltaValues = l.deltaKeys[:0], l.deltaValues[:0]
+	l.train()
+}
+
+// train fits the segments to the array with the shrinking cone algorithm. A segment starts at its first key, and keeps
+// the range of slopes for which all its keys so far are predicted within epsilon. When the next key does not fit any
+// slope in the range, the key starts a new segment.
+func (l *Learned[K, V]) train() {
+	l.segments = l.segments[:0]
+	eps := float64(l.epsilon)
+	var loSlope, hiSlope float64
+	for i, key := range l.keys {
+		if len(l.segments) > 0 {
+			seg := &l.segments[len(l.segments)-1]
+			dx, dy := keyDistance(seg.firstKey, key), float64(i-seg.start)
+			lo, hi := (dy-eps)/dx, (dy+eps)/dx
+			if lo <= hiSlope && hi >= loSlope {
+				loSlope, hiSlope = max(loSlope, lo), min(hiSlope, hi)
+				seg.slope = (loSlope + hiSlope) / 2
+				continue
+			}
+		}
+		l.segments = append(l.segments, segment[K]{firstKey: key, start: i})
+		loSlope, hiSlope = 0, math.Inf(1)
+	}
+}
+
+// lowerBound returns the first position in [lo, hi) of the sorted keys with the key not smaller than the key, or hi if
+// there is none.
+func lowerBound[K ~int](keys []K, lo, hi int, key K, ac func(n any)) int {
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		ac(&keys[mid])
+		if keys[mid] < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
@@ -0,0 +1,96 @@
+package learned_test
+
+import (
+	"btree-cache-benchmark/baseline/learned"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFind(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	sequence := []int{}
+	for i := range 10_000 {
+		sequence = append(sequence, i)
+	}
+	random := []int{}
+	for range 10_000 {
+		random = append(random, r.Int()-r.Int())
+	}
+	for name, values := range map[string][]int{
+		"sequence": sequence,
+		"shuffled": r.Perm(10_000),
+		"random":   random,
+	} {
+		for _, epsilon := range []int{0, 1, learned.DefaultEpsilon} {
+			t.Run(fmt.Sprintf("%s epsilon %d", name, epsilon), func(t *testing.T) {
+				l := learned.New[int, int](epsilon)
+				for _, v := range values {
+					l.Insert(v, v+1)
+				}
+				assert.NoError(t, l.IntegrityCheck())
+				assert.Equal(t, len(values), l.Len())
+				for _, v := range values {
+					actual, ok := l.Find(v)
+					assert.True(t, ok, "value not found for key %d", v)
+					assert.Equal(t, v+1, actual)
+				}
+				l.Insert(values[0], 0)
+				assert.Equal(t, len(values), l.Len())
+				v, _ := l.Find(values[0])
+				assert.Equal(t, 0, v)
+				for _, missing := range []int{-1, 10_000, values[0] + 1_000_000_007} {
+					if !slices.Contains(values, missing) {
+						_, ok := l.Find(missing)
+						assert.False(t, ok, "found missing key %d", missing)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSequenceIsOneSegment(t *testing.T) {
+	l := learned.New[int, int](learned.DefaultEpsilon)
+	for i := range 10_000 {
+		l.Insert(i*3, i)
+	}
+	assert.Equal(t, 1, l.Segments())
+	accesses := 0
+	l.SetAccessCounter(func(n any) { accesses++ })
+	l.Find(3 * 5000)
+	// The segment, the keys at the ends of the window, and the search in the window of 2*epsilon+1 keys.
+	assert.LessOrEqual(t, accesses, 1+2+6)
+}
+
+func TestScan(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	l := learned.New[int, int](4)
+	keys := []int{}
+	for range 3000 {
+		k := r.Intn(1 << 16)
+		l.Insert(k, k)
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	for range 100 {
+		lo := r.Intn(1 << 16)
+		hi := lo + r.Intn(1<<12)
+		expected := []int{}
+		for _, k := range keys {
+			if k >= lo && k < hi {
+				expected = append(expected, k)
+			}
+		}
+		actual := []int{}
+		l.Scan(lo, hi, func(key, value int) bool {
+			actual = append(actual, key)
+			return true
+		})
+		assert.Equal(t, expected, actual, "scan [%d, %d)", lo, hi)
+	}
+}
@@ -0,0 +1,286 @@
+// Package lsm is a log-structured merge tree kept in memory. Inserts go to a memtable, which is a btree.Btree, and
+// when the memtable is full it is flushed to an immutable sorted run. The runs are organised in levels and merged by
+// compaction as they accumulate. Lookups check the memtable and then the runs from the newest to the oldest.
+package lsm
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+)
+
+type LSM[K ~int, V any] struct {
+	options
+	// btreeOrder is the order of the memtable.
+	btreeOrder int
+	memtable   *btree.Btree[K, V]
+	// memtableInserts is the number of inserts into the memtable since the last flush.
+	memtableInserts int
+	// levels[i] are the runs of the level i, from the oldest to the newest. Lower levels have newer pairs.
+	levels           [][]*run[K, V]
+	stats            Stats
+	accessCounter    func(n any)
+	rebalanceCounter func()
+}
+
+// run is an immutable array of pairs sorted by unique keys.
+type run[K ~int, V any] struct {
+	keys   []K
+	values []V
+}
+
+// Stats are the counters of the work done by the LSM since it was created.
+type Stats struct {
+	// Inserts is the number of Insert calls.
+	Inserts int
+	// Written is the number of pairs written to sorted runs, by flushes and by compactions.
+	Written int
+	// Finds is the number of Find calls.
+	Finds int
+	// RunProbes is the number of runs searched by Find. A run whose keys are all smaller or all larger than the key is
+	// skipped without a probe.
+	RunProbes int
+	// Runs is the current number of runs in all the levels.
+	Runs int
+	// Levels is the current number of levels.
+	Levels int
+}
+
+// WriteAmplification is the number of times an inserted pair was written to a run, on average.
+func (s Stats) WriteAmplification() float64 {
+	if s.Inserts == 0 {
+		return 0
+	}
+	return float64(s.Written) / float64(s.Inserts)
+}
+
+func (s Stats) ProbesPerFind() float64 {
+	if s.Finds == 0 {
+		return 0
+	}
+	return float64(s.RunProbes) / float64(s.Finds)
+}
+
+////////////////////////////////////////
+// LSM functions and methods
+////////////////////////////////////////
+
+// New creates an LSM with a memtable of given B-tree order.
+func New[K ~int, V any](btreeOrder int, opts ...Option) *LSM[K, V] {
+	o := options{
+		memtableSize: DefaultMemtableSize,
+		fanout:       DefaultFanout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	l := &LSM[K, V]{
+		options:       o,
+		btreeOrder:    btreeOrder,
+		accessCounter: func(n any) {},
+	}
+	l.memtable = l.newMemtable()
+	return l
+}
+
+func (l *LSM[K, V]) newMemtable() *btree.Btree[K, V] {
+	m := btree.New[K, V](l.btreeOrder, l.btreeOptions...)
+	m.SetAccessCounter(l.accessCounter)
+	return m
+}
+
+// SetAccessCounter must be called right after New. The accesses are to the nodes of the memtable and to the probed
+// elements of the runs.
+func (l *LSM[K, V]) SetAccessCounter(ac func(n any)) {
+	l.accessCounter = ac
+	l.memtable.SetAccessCounter(ac)
+}
+
+// SetRebalanceCounter sets the counter called on every flush of the memtable and every compaction, which are the LSM
+// counterparts of the splits of B-tree nodes. The splits of the memtable nodes are not counted.
+func (l *LSM[K, V]) SetRebalanceCounter(rc func()) {
+	l.rebalanceCounter = rc
+}
+
+func (l *LSM[K, V]) Stats() Stats {
+	s := l.stats
+	s.Levels = len(l.levels)
+	for _, level := range l.levels {
+		s.Runs += len(level)
+	}
+	return s
+}
+
+// Insert replaces the value if the key is already present.
+func (l *LSM[K, V]) Insert(key K, value V) {
+	l.stats.Inserts++
+	l.memtable.Insert(key, value)
+	l.memtableInserts++
+	if l.memtableInserts >= l.memtableSize {
+		l.flush()
+	}
+}
+
+func (l *LSM[K, V]) Find(key K) (V, bool) {
+	l.stats.Finds++
+	if v, ok := l.memtable.Find(key); ok {
+		return v, true
+	}
+	for _, level := range l.levels {
+		for i := len(level) - 1; i >= 0; i-- {
+			r := level[i]
+			if key < r.keys[0] || key > r.keys[len(r.keys)-1] {
+				continue
+			}
+			l.stats.RunProbes++
+			if j, found := r.find(key, l.accessCounter); found {
+				return r.values[j], true
+			}
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// flush writes the memtable to a new run in the level 0, and compacts the levels.
+func (l *LSM[K, V]) flush() {
+	r := &run[K, V]{}
+	l.memtable.Ascend(func(key K, value V) bool {
+		r.keys = append(r.keys, key)
+		r.values = append(r.values, value)
+		return true
+	})
+	l.memtable = l.newMemtable()
+	l.memtableInserts = 0
+	if len(r.keys) == 0 {
+		return
+	}
+	l.stats.Written += len(r.keys)
+	l.countRebalance()
+	switch l.policy {
+	case Leveled:
+		l.compactLeveled(r)
+	case Tiered:
+		l.compactTiered(r)
+	default:
+		panic(fmt.Sprintf("unknown compaction policy %d", l.policy))
+	}
+}
+
+func (l *LSM[K, V]) compactLeveled(r *run[K, V]) {
+	capacity := l.memtableSize
+	for i := 0; ; i++ {
+		if i == len(l.levels) {
+			l.levels = append(l.levels, nil)
+		}
+		capacity *= l.fanout
+		if len(l.levels[i]) == 0 {
+			l.levels[i] = []*run[K, V]{r}
+			return
+		}
+		r = l.merge([]*run[K, V]{l.levels[i][0], r})
+		if len(r.keys) <= capacity {
+			l.levels[i][0] = r
+			return
+		}
+		l.levels[i] = nil
+	}
+}
+
+func (l *LSM[K, V]) compactTiered(r *run[K, V]) {
+	for i := 0; ; i++ {
+		if i == len(l.levels) {
+			l.levels = append(l.levels, nil)
+		}
+		l.levels[i] = append(l.levels[i], r)
+		if len(l.levels[i]) < l.fanout {
+			return
+		}
+		r = l.merge(l.levels[i])
+		l.levels[i] = nil
+	}
+}
+
+// merge merges the runs, from the oldest to the newest, into one run. The newest of equal keys is kept.
+func (l *LSM[K, V]) merge(runs []*run[K, V]) *run[K, V] {
+	l.countRebalance()
+	merged := runs[0]
+	for _, newer := range runs[1:] {
+		merged = mergeTwo(merged, newer)
+	}
+	l.stats.Written += len(merged.keys)
+	return merged
+}
+
+func mergeTwo[K ~int, V any](older, newer *run[K, V]) *run[K, V] {
+	n := len(older.keys) + len(newer.keys)
+	r := &run[K, V]{keys: make([]K, 0, n), values: make([]V, 0, n)}
+	i, j := 0, 0
+	for i < len(older.keys) || j < len(newer.keys) {
+		switch {
+		case j == len(newer.keys) || (i < len(older.keys) && older.keys[i] < newer.keys[j]):
+			r.keys, r.values = append(r.keys, older.keys[i]), append(r.values, older.values[i])
+			i++
+		default:
+			if i < len(older.keys) && older.keys[i] == newer.keys[j] {
+				i++
+			}
+			r.keys, r.values = append(r.keys, newer.keys[j]), append(r.values, newer.values[j])
+			j++
+		}
+	}
+	return r
+}
+
+func (l *LSM[K, V]) countRebalance() {
+	if l.rebalanceCounter != nil {
+		l.rebalanceCounter()
+	}
+}
+
+// IntegrityCheck checks the memtable, that the runs are sorted with unique keys, and that the levels are within the
+// limits of the compaction policy.
+func (l *LSM[K, V]) IntegrityCheck() error {
+	if err := l.memtable.IntegrityCheck(); err != nil {
+		return fmt.Errorf("memtable: %w", err)
+	}
+	capacity := l.memtableSize
+	for i, level := range l.levels {
+		capacity *= l.fanout
+		if l.policy == Leveled && (len(level) > 1 || (len(level) == 1 && len(level[0].keys) > capacity)) {
+			return fmt.Errorf("level %d has %d runs, over the capacity %d", i, len(level), capacity)
+		}
+		if l.policy == Tiered && len(level) >= l.fanout {
+			return fmt.Errorf("level %d has %d runs, not less than the fanout %d", i, len(level), l.fanout)
+		}
+		for _, r := range level {
+			if len(r.keys) != len(r.values) {
+				return fmt.Errorf("run of level %d has %d keys and %d values", i, len(r.keys), len(r.values))
+			}
+			for j := 1; j < len(r.keys); j++ {
+				if r.keys[j-1] >= r.keys[j] {
+					return fmt.Errorf("run of level %d is not sorted with unique keys at %v", i, r.keys[j])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////
+// Run functions and methods
+////////////////////////////////////////
+
+// find returns the position of the key in the run, and if the key is there.
+func (r *run[K, V]) find(key K, ac func(n any)) (int, bool) {
+	lo, hi := 0, len(r.keys)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		ac(&r.keys[mid])
+		if r.keys[mid] < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(r.keys) && r.keys[lo] == key
+}
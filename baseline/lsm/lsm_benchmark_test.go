@@ -0,0 +1,98 @@
+package lsm_test
+
+import (
+	"btree-cache-benchmark/baseline/lsm"
+	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/utils"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+const (
+	nValues = 100_000
+	order   = 10
+)
+
+var (
+	sequenceTypes = []string{"range", "shuffledRange", "random"}
+	policies      = []lsm.CompactionPolicy{lsm.Leveled, lsm.Tiered}
+	fanouts       = []int{4, 10}
+)
+
+// BenchmarkInsert reports the write amplification of the LSM, and the rebalances per insert next to the ones of a
+// plain B-tree, like count_rebalance counts them.
+func BenchmarkInsert(t *testing.B) {
+	for _, s := range sequenceTypes {
+		sequence := getSequence(nValues, s)
+		t.Run(fmt.Sprintf("n:%d_order:%d_seq:%s_impl:btree", nValues, order, s), func(b *testing.B) {
+			rebalances := 0
+			for range b.N {
+				m := btree.New[int, int](order)
+				m.SetRebalanceCounter(func() { rebalances++ })
+				for _, value := range sequence {
+					m.Insert(value, value)
+				}
+			}
+			b.ReportMetric(float64(rebalances)/float64(b.N*len(sequence)), "rebalances/insert")
+		})
+		for _, policy := range policies {
+			for _, fanout := range fanouts {
+				name := fmt.Sprintf("n:%d_order:%d_seq:%s_impl:lsm_policy:%s_fanout:%d", nValues, order, s, policy, fanout)
+				t.Run(name, func(b *testing.B) {
+					rebalances := 0
+					var stats lsm.Stats
+					for range b.N {
+						m := lsm.New[int, int](order, lsm.WithCompactionPolicy(policy), lsm.WithFanout(fanout))
+						m.SetRebalanceCounter(func() { rebalances++ })
+						for _, value := range sequence {
+							m.Insert(value, value)
+						}
+						stats = m.Stats()
+					}
+					b.ReportMetric(float64(rebalances)/float64(b.N*len(sequence)), "rebalances/insert")
+					b.ReportMetric(stats.WriteAmplification(), "write-amp")
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkFind reports the runs probed per lookup.
+func BenchmarkFind(t *testing.B) {
+	for _, s := range sequenceTypes {
+		sequence := getSequence(nValues, s)
+		lookups := slices.Clone(sequence)
+		utils.Shuffle(lookups)
+		for _, policy := range policies {
+			for _, fanout := range fanouts {
+				m := lsm.New[int, int](order, lsm.WithCompactionPolicy(policy), lsm.WithFanout(fanout))
+				for _, value := range sequence {
+					m.Insert(value, value)
+				}
+				name := fmt.Sprintf("n:%d_order:%d_seq:%s_impl:lsm_policy:%s_fanout:%d", nValues, order, s, policy, fanout)
+				t.Run(name, func(b *testing.B) {
+					before := m.Stats()
+					for range b.N {
+						for _, value := range lookups {
+							m.Find(value)
+						}
+					}
+					after := m.Stats()
+					b.ReportMetric(float64(after.RunProbes-before.RunProbes)/float64(after.Finds-before.Finds), "probes/find")
+				})
+			}
+		}
+	}
+}
+
+func getSequence(n int, t string) []int {
+	if t == "random" {
+		return utils.GetRandomArray(n)
+	}
+	s := utils.GetSequenceRange(n)
+	if t == "shuffledRange" {
+		utils.Shuffle(s)
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package lsm_test
+
+import (
+	"btree-cache-benchmark/baseline/lsm"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFind(t *testing.T) {
+	for _, policy := range []lsm.CompactionPolicy{lsm.Leveled, lsm.Tiered} {
+		t.Run(policy.String(), func(t *testing.T) {
+			l := lsm.New[int, int](5, lsm.WithCompactionPolicy(policy), lsm.WithMemtableSize(16), lsm.WithFanout(3))
+			rebalances := 0
+			l.SetRebalanceCounter(func() { rebalances++ })
+			values := rand.New(rand.NewSource(0)).Perm(10_000)
+			for _, v := range values {
+				l.Insert(v, v)
+			}
+			// Overwrite some keys, so the newer values must shadow the older ones in the runs.
+			for _, v := range values[:1000] {
+				l.Insert(v, -v)
+			}
+			assert.NoError(t, l.IntegrityCheck())
+			for i, v := range values {
+				expected := v
+				if i < 1000 {
+					expected = -v
+				}
+				actual, ok := l.Find(v)
+				assert.True(t, ok, "value not found for key %d", v)
+				assert.Equal(t, expected, actual)
+			}
+			_, ok := l.Find(-1)
+			assert.False(t, ok)
+
+			s := l.Stats()
+			assert.Equal(t, 11_000, s.Inserts)
+			assert.Equal(t, 10_001, s.Finds)
+			assert.Greater(t, s.WriteAmplification(), 1.0)
+			assert.Greater(t, s.ProbesPerFind(), 0.0)
+			assert.Greater(t, s.Levels, 1)
+			assert.GreaterOrEqual(t, rebalances, 11_000/16)
+		})
+	}
+}
+
+func TestSequentialRunsAreSkipped(t *testing.T) {
+	// Sequential keys make runs with disjoint key ranges, so a lookup probes at most one run per level.
+	l := lsm.New[int, int](5, lsm.WithCompactionPolicy(lsm.Tiered), lsm.WithMemtableSize(16), lsm.WithFanout(4))
+	for i := range 10_000 {
+		l.Insert(i, i)
+	}
+	for i := range 10_000 {
+		l.Find(i)
+	}
+	s := l.Stats()
+	assert.LessOrEqual(t, s.ProbesPerFind(), 1.0)
+}
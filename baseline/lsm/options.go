@@ -0,0 +1,68 @@
+package lsm
+
+import "btree-cache-benchmark/btree"
+
+// Option configures an LSM created with New.
+type Option func(*options)
+
+type options struct {
+	policy       CompactionPolicy
+	memtableSize int
+	fanout       int
+	btreeOptions []btree.Option
+}
+
+// CompactionPolicy is how the sorted runs are merged as they accumulate.
+type CompactionPolicy int
+
+const (
+	// Leveled keeps at most one run per level, and merges every run coming to a level into it. A level holds fanout
+	// times more pairs than the previous one, and the run that outgrows its level moves to the next one. Lookups probe at
+	// most one run per level, at the cost of rewriting the pairs once per run merged into their level.
+	Leveled CompactionPolicy = iota
+	// Tiered collects up to fanout runs per level, and merges them into a single run of the next level when the level is
+	// full. Every pair is rewritten once per level, but lookups probe up to fanout runs per level.
+	Tiered
+)
+
+func (p CompactionPolicy) String() string {
+	switch p {
+	case Leveled:
+		return "leveled"
+	case Tiered:
+		return "tiered"
+	}
+	return "unknown"
+}
+
+const (
+	DefaultMemtableSize = 1024
+	DefaultFanout       = 4
+)
+
+func WithCompactionPolicy(policy CompactionPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithMemtableSize sets the number of inserts after which the memtable is flushed to a sorted run.
+func WithMemtableSize(size int) Option {
+	return func(o *options) {
+		o.memtableSize = size
+	}
+}
+
+// WithFanout sets the ratio of the sizes of the consecutive levels.
+func WithFanout(fanout int) Option {
+	return func(o *options) {
+		o.fanout = fanout
+	}
+}
+
+// WithBtreeOptions sets the options of the memtable.
+func WithBtreeOptions(opts ...btree.Option) Option {
+	return func(o *options) {
+		o.btreeOptions = opts
+	}
+}
@@ -0,0 +1,189 @@
+// Package rbtree is an ordered map in a left-leaning red-black tree, the pointer-heavy baseline with one key per node.
+package rbtree
+
+import (
+	"cmp"
+	"fmt"
+)
+
+type RBTree[K cmp.Ordered, V any] struct {
+	root             *node[K, V]
+	len              int
+	accessCounter    func(n any)
+	rebalanceCounter func()
+}
+
+type node[K cmp.Ordered, V any] struct {
+	key         K
+	value       V
+	left, right *node[K, V]
+	// red is the color of the link from the parent.
+	red bool
+}
+
+func New[K cmp.Ordered, V any]() *RBTree[K, V] {
+	return &RBTree[K, V]{
+		accessCounter: func(n any) {},
+	}
+}
+
+// SetAccessCounter must be called right after New.
+func (t *RBTree[K, V]) SetAccessCounter(ac func(n any)) {
+	t.accessCounter = ac
+}
+
+// SetRebalanceCounter sets the counter called on every rotation.
+func (t *RBTree[K, V]) SetRebalanceCounter(rc func()) {
+	t.rebalanceCounter = rc
+}
+
+func (t *RBTree[K, V]) Len() int {
+	return t.len
+}
+
+func (t *RBTree[K, V]) Find(key K) (V, bool) {
+	for n := t.root; n != nil; {
+		t.accessCounter(n)
+		switch {
+		case key < n.key:
+			n = n.left
+		case key > n.key:
+			n = n.right
+		default:
+			return n.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Insert replaces the value if the key is already present.
+func (t *RBTree[K, V]) Insert(key K, value V) {
+	t.root = t.insertRec(t.root, key, value)
+	t.root.red = false
+}
+
+func (t *RBTree[K, V]) insertRec(n *node[K, V], key K, value V) *node[K, V] {
+	if n == nil {
+		t.len++
+		return &node[K, V]{key: key, value: value, red: true}
+	}
+	t.accessCounter(n)
+	switch {
+	case key < n.key:
+		n.left = t.insertRec(n.left, key, value)
+	case key > n.key:
+		n.right = t.insertRec(n.right, key, value)
+	default:
+		n.value = value
+	}
+	if isRed(n.right) && !isRed(n.left) {
+		n = t.rotateLeft(n)
+	}
+	if isRed(n.left) && isRed(n.left.left) {
+		n = t.rotateRight(n)
+	}
+	if isRed(n.left) && isRed(n.right) {
+		flipColors(n)
+	}
+	return n
+}
+
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false.
+func (t *RBTree[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	t.scanRec(t.root, lo, hi, fun)
+}
+
+func (t *RBTree[K, V]) scanRec(n *node[K, V], lo, hi K, fun func(key K, value V) bool) bool {
+	if n == nil {
+		return true
+	}
+	t.accessCounter(n)
+	if lo < n.key && !t.scanRec(n.left, lo, hi, fun) {
+		return false
+	}
+	if lo <= n.key && n.key < hi && !fun(n.key, n.value) {
+		return false
+	}
+	if n.key < hi {
+		return t.scanRec(n.right, lo, hi, fun)
+	}
+	return true
+}
+
+// IntegrityCheck checks that the keys are ordered, there are no red right links nor two red links in a row, and all
+// the paths from the root to the leaves have the same number of black links.
+func (t *RBTree[K, V]) IntegrityCheck() error {
+	if isRed(t.root) {
+		return fmt.Errorf("root is red")
+	}
+	_, err := integrityCheckRec(t.root, nil, nil)
+	return err
+}
+
+// integrityCheckRec returns the number of black links on the paths from n to the leaves. nil bound means no bound.
+func integrityCheckRec[K cmp.Ordered, V any](n *node[K, V], lo, hi *K) (int, error) {
+	if n == nil {
+		return 0, nil
+	}
+	if (lo != nil && n.key <= *lo) || (hi != nil && n.key >= *hi) {
+		return 0, fmt.Errorf("key %v outside of parent bounds", n.key)
+	}
+	if isRed(n.right) {
+		return 0, fmt.Errorf("right link of %v is red", n.key)
+	}
+	if isRed(n) && isRed(n.left) {
+		return 0, fmt.Errorf("two red links in a row at %v", n.key)
+	}
+	leftBlack, err := integrityCheckRec(n.left, lo, &n.key)
+	if err != nil {
+		return 0, err
+	}
+	rightBlack, err := integrityCheckRec(n.right, &n.key, hi)
+	if err != nil {
+		return 0, err
+	}
+	if leftBlack != rightBlack {
+		return 0, fmt.Errorf("black height differs at %v, left %d, right %d", n.key, leftBlack, rightBlack)
+	}
+	if !isRed(n) {
+		leftBlack++
+	}
+	return leftBlack, nil
+}
+
+func (t *RBTree[K, V]) rotateLeft(n *node[K, V]) *node[K, V] {
+	if t.rebalanceCounter != nil {
+		t.rebalanceCounter()
+	}
+	x := n.right
+	t.accessCounter(x)
+	n.right = x.left
+	x.left = n
+	x.red = n.red
+	n.red = true
+	return x
+}
+
+func (t *RBTree[K, V]) rotateRight(n *node[K, V]) *node[K, V] {
+	if t.rebalanceCounter != nil {
+		t.rebalanceCounter()
+	}
+	x := n.left
+	t.accessCounter(x)
+	n.left = x.right
+	x.right = n
+	x.red = n.red
+	n.red = true
+	return x
+}
+
+func flipColors[K cmp.Ordered, V any](n *node[K, V]) {
+	n.red = !n.red
+	n.left.red = !n.left.red
+	n.right.red = !n.right.red
+}
+
+func isRed[K cmp.Ordered, V any](n *node[K, V]) bool {
+	return n != nil && n.red
+}
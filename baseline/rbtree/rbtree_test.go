@@ -0,0 +1,61 @@
+package rbtree_test
+
+import (
+	"btree-cache-benchmark/baseline/rbtree"
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFindScan(t *testing.T) {
+	sequence := []int{}
+	for i := range 1000 {
+		sequence = append(sequence, i)
+	}
+	for name, values := range map[string][]int{
+		"sequence": sequence,
+		"shuffled": rand.New(rand.NewSource(0)).Perm(1000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := rbtree.New[int, int]()
+			for _, v := range values {
+				b.Insert(v, v)
+				assert.NoError(t, b.IntegrityCheck())
+			}
+			b.Insert(10, 110)
+			assert.NoError(t, b.IntegrityCheck())
+			assert.Equal(t, 1000, b.Len())
+			for _, v := range values {
+				_, ok := b.Find(v)
+				assert.True(t, ok, "value not found for key %d", v)
+			}
+			v, _ := b.Find(10)
+			assert.Equal(t, 110, v)
+			_, ok := b.Find(1000)
+			assert.False(t, ok)
+
+			keys := []int{}
+			b.Scan(-10, 3, func(key, value int) bool {
+				keys = append(keys, key)
+				return true
+			})
+			assert.Equal(t, []int{0, 1, 2}, keys)
+		})
+	}
+}
+
+func TestFindAccessesAtMostTwiceLogN(t *testing.T) {
+	b := rbtree.New[int, int]()
+	for i := range 1 << 12 {
+		b.Insert(i, i)
+	}
+	accesses := 0
+	b.SetAccessCounter(func(n any) { accesses++ })
+	for i := range 1 << 12 {
+		accesses = 0
+		b.Find(i)
+		assert.LessOrEqual(t, accesses, 2*bits.Len(1<<12))
+	}
+}
@@ -0,0 +1,131 @@
+// Package skiplist is an ordered map in a skip list, the pointer-heavy baseline with probabilistic balancing.
+package skiplist
+
+import (
+	"cmp"
+	"math/rand"
+)
+
+const (
+	maxLevel = 32
+	// 1/p is the expected number of nodes per node at the next level.
+	p = 4
+)
+
+type SkipList[K cmp.Ordered, V any] struct {
+	// head is a sentinel node with no key, linking to the first node at every level.
+	head          *node[K, V]
+	level         int
+	len           int
+	rand          *rand.Rand
+	accessCounter func(n any)
+}
+
+type node[K cmp.Ordered, V any] struct {
+	key   K
+	value V
+	// next[i] is the next node at level i.
+	next []*node[K, V]
+}
+
+func New[K cmp.Ordered, V any]() *SkipList[K, V] {
+	return &SkipList[K, V]{
+		head:          &node[K, V]{next: make([]*node[K, V], maxLevel)},
+		level:         1,
+		rand:          rand.New(rand.NewSource(0)),
+		accessCounter: func(n any) {},
+	}
+}
+
+// SetAccessCounter must be called right after New.
+func (s *SkipList[K, V]) SetAccessCounter(ac func(n any)) {
+	s.accessCounter = ac
+}
+
+func (s *SkipList[K, V]) Len() int {
+	return s.len
+}
+
+func (s *SkipList[K, V]) Find(key K) (V, bool) {
+	if n := s.findGreaterOrEqual(key, nil); n != nil && n.key == key {
+		return n.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Insert replaces the value if the key is already present.
+func (s *SkipList[K, V]) Insert(key K, value V) {
+	var prev [maxLevel]*node[K, V]
+	if n := s.findGreaterOrEqual(key, &prev); n != nil && n.key == key {
+		n.value = value
+		return
+	}
+	level := s.randomLevel()
+	for i := s.level; i < level; i++ {
+		prev[i] = s.head
+	}
+	s.level = max(s.level, level)
+	n := &node[K, V]{key: key, value: value, next: make([]*node[K, V], level)}
+	for i := range level {
+		s.accessCounter(prev[i])
+		n.next[i] = prev[i].next[i]
+		prev[i].next[i] = n
+	}
+	s.len++
+}
+
+func (s *SkipList[K, V]) Delete(key K) bool {
+	var prev [maxLevel]*node[K, V]
+	n := s.findGreaterOrEqual(key, &prev)
+	if n == nil || n.key != key {
+		return false
+	}
+	for i := range n.next {
+		s.accessCounter(prev[i])
+		prev[i].next[i] = n.next[i]
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+	s.len--
+	return true
+}
+
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false.
+func (s *SkipList[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	for n := s.findGreaterOrEqual(lo, nil); n != nil && n.key < hi; n = n.next[0] {
+		s.accessCounter(n)
+		if !fun(n.key, n.value) {
+			return
+		}
+	}
+}
+
+// findGreaterOrEqual returns the first node with the key not smaller than the key, or nil if there is none. If prev is
+// not nil, it is filled with the last node before the returned one at each level.
+func (s *SkipList[K, V]) findGreaterOrEqual(key K, prev *[maxLevel]*node[K, V]) *node[K, V] {
+	x := s.head
+	s.accessCounter(x)
+	for i := s.level - 1; i >= 0; i-- {
+		for next := x.next[i]; next != nil; next = x.next[i] {
+			s.accessCounter(next)
+			if next.key >= key {
+				break
+			}
+			x = next
+		}
+		if prev != nil {
+			prev[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+func (s *SkipList[K, V]) randomLevel() int {
+	level := 1
+	for level < maxLevel && s.rand.Intn(p) == 0 {
+		level++
+	}
+	return level
+}
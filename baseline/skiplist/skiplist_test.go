@@ -0,0 +1,47 @@
+package skiplist_test
+
+import (
+	"btree-cache-benchmark/baseline/skiplist"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFindScanDelete(t *testing.T) {
+	s := skiplist.New[int, int]()
+	values := rand.New(rand.NewSource(0)).Perm(1000)
+	for _, v := range values {
+		s.Insert(v, v)
+	}
+	s.Insert(10, 110)
+	assert.Equal(t, 1000, s.Len())
+	for _, v := range values {
+		actual, ok := s.Find(v)
+		assert.True(t, ok, "value not found for key %d", v)
+		if v != 10 {
+			assert.Equal(t, v, actual)
+		}
+	}
+	v, _ := s.Find(10)
+	assert.Equal(t, 110, v)
+	_, ok := s.Find(-1)
+	assert.False(t, ok)
+
+	keys := []int{}
+	s.Scan(998, 2000, func(key, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []int{998, 999}, keys)
+
+	for _, v := range values[:500] {
+		assert.True(t, s.Delete(v))
+	}
+	assert.False(t, s.Delete(values[0]))
+	assert.Equal(t, 500, s.Len())
+	for _, v := range values[500:] {
+		_, ok := s.Find(v)
+		assert.True(t, ok, "value not found for key %d after deletes", v)
+	}
+}
@@ -0,0 +1,86 @@
+// Package sortedslice is an ordered map in a single sorted array, with binary search and insertion by shifting the
+// larger pairs. It is the most contiguous baseline to compare B-trees with.
+package sortedslice
+
+import (
+	"cmp"
+	"slices"
+)
+
+type SortedSlice[K cmp.Ordered, V any] struct {
+	pairs         []pair[K, V]
+	accessCounter func(n any)
+}
+
+type pair[K any, V any] struct {
+	key   K
+	value V
+}
+
+func New[K cmp.Ordered, V any]() *SortedSlice[K, V] {
+	return &SortedSlice[K, V]{
+		accessCounter: func(n any) {},
+	}
+}
+
+// SetAccessCounter sets the counter called with each probed array element, since the array has no nodes.
+func (s *SortedSlice[K, V]) SetAccessCounter(ac func(n any)) {
+	s.accessCounter = ac
+}
+
+func (s *SortedSlice[K, V]) Len() int {
+	return len(s.pairs)
+}
+
+func (s *SortedSlice[K, V]) Find(key K) (V, bool) {
+	if i, found := s.bisect(key); found {
+		return s.pairs[i].value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Insert replaces the value if the key is already present.
+func (s *SortedSlice[K, V]) Insert(key K, value V) {
+	i, found := s.bisect(key)
+	if found {
+		s.pairs[i].value = value
+		return
+	}
+	s.pairs = slices.Insert(s.pairs, i, pair[K, V]{key: key, value: value})
+}
+
+func (s *SortedSlice[K, V]) Delete(key K) bool {
+	i, found := s.bisect(key)
+	if !found {
+		return false
+	}
+	s.pairs = slices.Delete(s.pairs, i, i+1)
+	return true
+}
+
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false.
+func (s *SortedSlice[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	i, _ := s.bisect(lo)
+	for ; i < len(s.pairs) && s.pairs[i].key < hi; i++ {
+		s.accessCounter(&s.pairs[i])
+		if !fun(s.pairs[i].key, s.pairs[i].value) {
+			return
+		}
+	}
+}
+
+// bisect returns the index of the first key not smaller than the key, and if it is equal to the key.
+func (s *SortedSlice[K, V]) bisect(key K) (int, bool) {
+	lo, hi := 0, len(s.pairs)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		s.accessCounter(&s.pairs[mid])
+		if s.pairs[mid].key < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(s.pairs) && s.pairs[lo].key == key
+}
@@ -0,0 +1,47 @@
+package sortedslice_test
+
+import (
+	"btree-cache-benchmark/baseline/sortedslice"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertFindScanDelete(t *testing.T) {
+	s := sortedslice.New[int, int]()
+	for _, v := range rand.New(rand.NewSource(0)).Perm(1000) {
+		s.Insert(v, v)
+	}
+	s.Insert(10, 110)
+	assert.Equal(t, 1000, s.Len())
+	v, ok := s.Find(10)
+	assert.True(t, ok)
+	assert.Equal(t, 110, v)
+	_, ok = s.Find(1000)
+	assert.False(t, ok)
+
+	keys := []int{}
+	s.Scan(500, 505, func(key, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []int{500, 501, 502, 503, 504}, keys)
+
+	assert.True(t, s.Delete(500))
+	assert.False(t, s.Delete(500))
+	_, ok = s.Find(500)
+	assert.False(t, ok)
+	assert.Equal(t, 999, s.Len())
+}
+
+func TestAccessCounter(t *testing.T) {
+	s := sortedslice.New[int, int]()
+	for i := range 1024 {
+		s.Insert(i, i)
+	}
+	accesses := 0
+	s.SetAccessCounter(func(n any) { accesses++ })
+	s.Find(100)
+	assert.Equal(t, 10, accesses, "binary search over 1024 elements probes 10 of them")
+}
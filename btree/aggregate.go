@@ -0,0 +1,83 @@
+package btree
+
+// Monoid aggregates the values of a range of keys, like their sum, minimum or maximum. The aggregate of a range is
+// Combine of Lift of its values in the ascending order of the keys, starting with Identity, so Combine must be
+// associative, but not necessarily commutative, and Identity must be its identity element.
+type Monoid[V any] struct {
+	Identity V
+	Combine  func(a, b V) V
+	// Lift maps a value to its aggregate, like a value to itself for the sum, or to 1 for the count.
+	Lift func(value V) V
+}
+
+// NewWithMonoid is New for a tree that keeps the aggregates of the sub-trees of the children of the inner nodes, so
+// Aggregate takes O(log n). Every insert recomputes the aggregates on the path to the leaf, in O(order * height). The
+// aggregates are kept in the inner nodes, with the counts of WithSubtreeCounts.
+func NewWithMonoid[K ~int, V any](order int, monoid Monoid[V], opts ...Option) *Btree[K, V] {
+	b := New[K, V](order, opts...)
+	b.monoid = &monoid
+	return b
+}
+
+// Aggregate returns the aggregate of the values of the keys in [lo, hi) range, the ones Scan calls its function for.
+// It panics if the tree is not created with NewWithMonoid.
+func (b *Btree[K, V]) Aggregate(lo, hi K) V {
+	if b.monoid == nil {
+		panic("Aggregate of a tree created without a monoid")
+	}
+	if hi <= lo {
+		return b.monoid.Identity
+	}
+	return b.aggregateRange(b.root, lo, hi, nil, nil)
+}
+
+// aggregateRange returns the aggregate of the keys of the sub-tree in [lo, hi) range, given that all the keys of the
+// sub-tree are in [nodeLo, nodeHi) range, where nil bound means no bound. The children entirely in the range are
+// aggregated by their cached aggregates, so only the sub-trees at both ends of the range are visited, O(height) of
+// them.
+func (b *Btree[K, V]) aggregateRange(n node[K, V], lo, hi K, nodeLo, nodeHi *K) V {
+	monoid := b.monoid
+	result := monoid.Identity
+	switch t := n.(type) {
+	case leafNode[K, V]:
+		t.scan(lo, hi, func(key K, value V) bool {
+			result = monoid.Combine(result, monoid.Lift(value))
+			return true
+		})
+	case *innerNode[K, V]:
+		t.countAccess()
+		for i := range t.children {
+			childLo, childHi := childBounds(t, i, nodeLo, nodeHi)
+			if childHi != nil && *childHi <= lo {
+				continue
+			}
+			if childLo != nil && *childLo >= hi {
+				break
+			}
+			if childLo != nil && *childLo >= lo && childHi != nil && *childHi <= hi {
+				result = monoid.Combine(result, t.summaries.aggregates[i])
+			} else {
+				result = monoid.Combine(result, b.aggregateRange(t.children[i], lo, hi, childLo, childHi))
+			}
+		}
+	}
+	return result
+}
+
+// aggregate returns the aggregate of the values in the sub-tree, from the aggregates of the children if the node is
+// an inner one.
+func (b *Btree[K, V]) aggregate(n node[K, V]) V {
+	n.countAccess()
+	result := b.monoid.Identity
+	switch t := n.(type) {
+	case *innerNode[K, V]:
+		for _, a := range t.summaries.aggregates {
+			result = b.monoid.Combine(result, a)
+		}
+	case leafNode[K, V]:
+		for i := range t.len() {
+			result = b.monoid.Combine(result, b.monoid.Lift(t.valueAt(i)))
+		}
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package btree
+
+import (
+	"cmp"
+	"math/rand"
+)
+
+// allocChunkSize is the number of nodes that a nodeAllocator preallocates at once. The nodes are shuffled within a chunk,
+// so the chunk should be much larger than the cache.
+const allocChunkSize = 1 << 14
+
+// nodeAllocator preallocates nodes and hands them out in random order, so that the placement of the nodes in memory is
+// unrelated to the order in which the tree creates them. The arrays of the nodes (keys, children, pairs) are
+// preallocated with the nodes, with room for the overflow before split. A nil *nodeAllocator allocates the nodes
+// on the heap, as they are created.
+type nodeAllocator[K cmp.Ordered, V any] struct {
+	order      int
+	leafOrder  int
+	leafLayout LeafLayout
+	rand       *rand.Rand
+	inners     []*innerNode[K, V]
+	leafs      []leafNode[K, V]
+}
+
+func newNodeAllocator[K cmp.Ordered, V any](order, leafOrder int, leafLayout LeafLayout, seed int64) *nodeAllocator[K, V] {
+	return &nodeAllocator[K, V]{
+		order:      order,
+		leafOrder:  leafOrder,
+		leafLayout: leafLayout,
+		rand:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (a *nodeAllocator[K, V]) newInnerNode(ac accessCounter, owner uint64) *innerNode[K, V] {
+	if a == nil {
+		return &innerNode[K, V]{owner: owner, accessCounter: ac}
+	}
+	if len(a.inners) == 0 {
+		a.preallocateInnerNodes()
+	}
+	n := a.inners[len(a.inners)-1]
+	a.inners = a.inners[:len(a.inners)-1]
+	n.owner = owner
+	n.accessCounter = ac
+	return n
+}
+
+func (a *nodeAllocator[K, V]) newLeafNode(ac accessCounter, owner uint64, layout LeafLayout) leafNode[K, V] {
+	if a == nil {
+		return newLeafNode[K, V](ac, owner, layout)
+	}
+	if assertionsEnabled {
+		assert(layout == a.leafLayout, "leaf layout %s differs from the allocator layout %s", layout, a.leafLayout)
+	}
+	if len(a.leafs) == 0 {
+		a.preallocateLeafNodes()
+	}
+	n := a.leafs[len(a.leafs)-1]
+	a.leafs = a.leafs[:len(a.leafs)-1]
+	n.setOwner(owner)
+	n.setAccessCounter(ac)
+	return n
+}
+
+func (a *nodeAllocator[K, V]) preallocateInnerNodes() {
+	nodes := make([]innerNode[K, V], allocChunkSize)
+	keys := make([]K, 0, allocChunkSize*a.order)
+	children := make([]node[K, V], 0, allocChunkSize*(a.order+1))
+	for i := range nodes {
+		nodes[i].keys = carve(&keys, nil, a.order)
+		nodes[i].children = carve(&children, nil, a.order+1)
+		a.inners = append(a.inners, &nodes[i])
+	}
+	a.rand.Shuffle(len(a.inners), func(i, j int) { a.inners[i], a.inners[j] = a.inners[j], a.inners[i] })
+}
+
+func (a *nodeAllocator[K, V]) preallocateLeafNodes() {
+	switch a.leafLayout {
+	case LeafLayoutSoA:
+		nodes := make([]soaLeafNode[K, V], allocChunkSize)
+		keys := make([]K, 0, allocChunkSize*(a.leafOrder+1))
+		values := make([]V, 0, allocChunkSize*(a.leafOrder+1))
+		for i := range nodes {
+			nodes[i].keys = carve(&keys, nil, a.leafOrder+1)
+			nodes[i].values = carve(&values, nil, a.leafOrder+1)
+			a.leafs = append(a.leafs, &nodes[i])
+		}
+	case leafLayoutKeys:
+		nodes := make([]keysLeafNode[K, V], allocChunkSize)
+		keys := make([]K, 0, allocChunkSize*(a.leafOrder+1))
+		for i := range nodes {
+			nodes[i].keys = carve(&keys, nil, a.leafOrder+1)
+			a.leafs = append(a.leafs, &nodes[i])
+		}
+	default:
+		nodes := make([]aosLeafNode[K, V], allocChunkSize)
+		pairs := make([]pair[K, V], 0, allocChunkSize*(a.leafOrder+1))
+		for i := range nodes {
+			nodes[i].pairs = carve(&pairs, nil, a.leafOrder+1)
+			a.leafs = append(a.leafs, &nodes[i])
+		}
+	}
+	a.rand.Shuffle(len(a.leafs), func(i, j int) { a.leafs[i], a.leafs[j] = a.leafs[j], a.leafs[i] })
+}
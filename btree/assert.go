@@ -1,9 +1,13 @@
-//go:build assertions
-
 package btree
 
 import "fmt"
 
+// assert panics if the condition is false. The calls must be guarded by assertionsEnabled, so the condition and the
+// message are not evaluated in the builds without the assertions tag:
+//
+//	if assertionsEnabled {
+//		assert(n.isSorted(), "pairs should be sorted")
+//	}
 func assert(condition bool, message ...any) {
 	if !condition {
 		if len(message) == 0 {
@@ -0,0 +1,5 @@
+//go:build assertions
+
+package btree
+
+const assertionsEnabled = true
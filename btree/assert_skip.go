@@ -2,5 +2,5 @@
 
 package btree
 
-func assert(condition bool, message ...any) {
-}
+// assertionsEnabled is a constant, so the compiler removes the guarded assertions.
+const assertionsEnabled = false
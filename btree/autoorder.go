@@ -0,0 +1,45 @@
+package btree
+
+import (
+	"cmp"
+	"unsafe"
+)
+
+// CacheLineSize is the assumed size of the CPU cache line in bytes.
+const CacheLineSize = 64
+
+// AutoOrderResult is the order of inner nodes and leafs chosen by AutoOrder, and the resulting sizes of full nodes.
+type AutoOrderResult struct {
+	// Order is the maximum number of children of an inner node, to be used as New order.
+	Order int
+	// LeafOrder is the maximum number of pairs in a leaf, to be used with WithLeafOrder.
+	LeafOrder int
+	// InnerNodeBytes is the size of a full inner node, i.e. the node struct and its keys and children arrays.
+	InnerNodeBytes int
+	// LeafNodeBytes is the size of a full leaf, i.e. the node struct and its pairs array.
+	LeafNodeBytes int
+}
+
+// AutoOrder chooses the order of inner nodes and leafs so that the keys and children of a full inner node, and the
+// pairs of a full leaf (with LeafLayoutAoS), fit in the given number of cache lines. The order is never lower than 2.
+func AutoOrder[K cmp.Ordered, V any](cacheLinesPerNode int) AutoOrderResult {
+	var key K
+	var child node[K, V]
+	var p pair[K, V]
+	keySize, childSize, pairSize := int(unsafe.Sizeof(key)), int(unsafe.Sizeof(child)), int(unsafe.Sizeof(p))
+	target := cacheLinesPerNode * CacheLineSize
+	// A node of order m has m-1 keys and m children.
+	order := max((target+keySize)/(keySize+childSize), 2)
+	leafOrder := max(target/max(pairSize, 1), 2)
+	return AutoOrderResult{
+		Order:          order,
+		LeafOrder:      leafOrder,
+		InnerNodeBytes: int(unsafe.Sizeof(innerNode[K, V]{})) + (order-1)*keySize + order*childSize,
+		LeafNodeBytes:  int(unsafe.Sizeof(aosLeafNode[K, V]{})) + leafOrder*pairSize,
+	}
+}
+
+// Options returns the options to use with New, along with the Order.
+func (r AutoOrderResult) Options() []Option {
+	return []Option{WithLeafOrder(r.LeafOrder)}
+}
@@ -0,0 +1,47 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoOrder(t *testing.T) {
+	r := btree.AutoOrder[int, int](1)
+	// 64 bytes fit 8 keys of 8 bytes, or 4 pairs of 16 bytes. An inner node of order m has m-1 keys and m children of
+	// 16 bytes, so only order 3 fits.
+	assert.Equal(t, 3, r.Order)
+	assert.Equal(t, 4, r.LeafOrder)
+
+	r = btree.AutoOrder[int, [32]int64](1)
+	assert.Equal(t, 3, r.Order)
+	assert.Equal(t, 2, r.LeafOrder, "leaf order is never lower than 2")
+
+	small, large := btree.AutoOrder[int, int](2), btree.AutoOrder[int, int](8)
+	assert.Less(t, small.Order, large.Order)
+	assert.Less(t, small.LeafOrder, large.LeafOrder)
+	assert.Less(t, small.InnerNodeBytes, large.InnerNodeBytes)
+	assert.Less(t, small.LeafNodeBytes, large.LeafNodeBytes)
+}
+
+func TestLeafOrder(t *testing.T) {
+	for _, orders := range [][2]int{{3, 1}, {3, 2}, {3, 10}, {10, 3}} {
+		t.Run(fmt.Sprintf("order %d leaf order %d", orders[0], orders[1]), func(t *testing.T) {
+			b := btree.New[int, int](orders[0], btree.WithLeafOrder(orders[1]))
+			for i := range 1000 {
+				b.Insert(i, i)
+			}
+			assert.NoError(t, b.IntegrityCheck())
+			for i := range 1000 {
+				assertFound(t, b, i, i)
+			}
+		})
+	}
+}
+
+func TestLeafOrderInvalid(t *testing.T) {
+	assert.Panics(t, func() { btree.WithLeafOrder(0) })
+	assert.Panics(t, func() { btree.WithLeafOrder(-1) })
+}
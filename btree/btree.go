@@ -20,6 +20,7 @@ package btree
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
 	"io"
 	"slices"
@@ -30,8 +31,23 @@ import (
 type Btree[K cmp.Ordered, V any] struct {
 	// The maximum number of child nodes of a node.
 	order int
+	// The maximum number of pairs in a leaf node, the same as order unless WithLeafOrder is used.
+	leafOrder  int
+	leafLayout LeafLayout
 	// either innerNode or leafNode
-	root             node[K, V]
+	root node[K, V]
+	// allocator is nil unless WithShuffledAllocation is used.
+	allocator *nodeAllocator[K, V]
+	// paranoid is nil unless WithParanoidChecks is used.
+	paranoid *paranoidChecks
+	// subtreeCounts is true if the tree keeps the counts, see WithSubtreeCounts.
+	subtreeCounts bool
+	// monoid is nil unless the tree is created with NewWithMonoid.
+	monoid *Monoid[V]
+	// owner is the id of the tree, the nodes with the same owner are not shared with a clone, see Clone.
+	owner uint64
+	// shared is true if some of the nodes may be shared with a clone, so Insert copies the nodes it does not own.
+	shared           bool
 	accessCounter    accessCounter
 	rebalanceCounter rebalanceCounter
 }
@@ -53,11 +69,13 @@ type Btree[K cmp.Ordered, V any] struct {
 type node[K cmp.Ordered, V any] interface {
 	// findLeafNodeByKey returns the leaf node that holds the value with seeked key, or the one that should
 	// hold such a value if it doesn't.
-	findLeafNodeByKey(key K) *leafNode[K, V]
+	findLeafNodeByKey(key K) leafNode[K, V]
 	isRoot() bool
 	getParent() *innerNode[K, V]
 	setParent(parent *innerNode[K, V])
 	runRecursiveUntilError(level int, fun func(level int, n node[K, V]) error) error
+	// scan calls fun for the pairs in [lo, hi) range in the sub-tree, and returns false if fun stopped the scan.
+	scan(lo, hi K, fun func(key K, value V) bool) bool
 	// The returned node is (optional) new root node.
 	// insertNodesToParentRec(child, left, right node[K, V], order int, median K) *innerNode[K, V]
 	print(w io.Writer, indent int)
@@ -68,20 +86,50 @@ type node[K cmp.Ordered, V any] interface {
 // Btree functions and methods
 ////////////////////////////////////////
 
-func New[K ~int, V any](order int) *Btree[K, V] {
+func New[K ~int, V any](order int, opts ...Option) *Btree[K, V] {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	ac := dummyAccessCounter
-	root := newLeafNode[K, V](ac)
-	return &Btree[K, V]{
+	leafOrder := order
+	if o.leafOrder != 0 {
+		leafOrder = o.leafOrder
+	}
+	var allocator *nodeAllocator[K, V]
+	if o.shuffledAllocation {
+		allocator = newNodeAllocator[K, V](order, leafOrder, o.leafLayout, o.shuffledAllocationSeed)
+	}
+	var paranoid *paranoidChecks
+	if o.paranoidLevel != ParanoidOff {
+		paranoid = newParanoidChecks(o.paranoidLevel, o.paranoidSampleRate)
+	}
+	b := &Btree[K, V]{
 		order:         order,
-		root:          root,
+		leafOrder:     leafOrder,
+		leafLayout:    o.leafLayout,
+		allocator:     allocator,
+		paranoid:      paranoid,
+		subtreeCounts: o.subtreeCounts,
+		owner:         newOwner(),
 		accessCounter: ac,
 	}
+	b.root = b.newLeafNode()
+	return b
+}
+
+func (b *Btree[K, V]) newInnerNode() *innerNode[K, V] {
+	return b.allocator.newInnerNode(b.accessCounter, b.owner)
+}
+
+func (b *Btree[K, V]) newLeafNode() leafNode[K, V] {
+	return b.allocator.newLeafNode(b.accessCounter, b.owner, b.leafLayout)
 }
 
 // SetAccessCounter must be called right after New.
 func (b *Btree[K, V]) SetAccessCounter(ac accessCounter) {
 	b.accessCounter = ac
-	b.root.(*leafNode[K, V]).accessCounter = ac
+	b.root.(leafNode[K, V]).setAccessCounter(ac)
 }
 
 func (b *Btree[K, V]) SetRebalanceCounter(rc rebalanceCounter) {
@@ -96,17 +144,30 @@ func (b *Btree[K, V]) Find(key K) (V, bool) {
 	return zero, false
 }
 
+// Insert replaces the value if the key is already present. After Clone, the nodes on the path to the leaf that are
+// shared with a clone are copied first.
 func (b *Btree[K, V]) Insert(key K, value V) {
 	// https://en.wikipedia.org/wiki/B-tree#Insertion
-	leafNode := b.root.findLeafNodeByKey(key)
-	assert(leafNode != nil, "there always must be some leaf node, not found for key %s", key)
+	var leafNode leafNode[K, V]
+	if b.shared {
+		leafNode = b.mutableLeaf(key)
+	} else {
+		leafNode = b.root.findLeafNodeByKey(key)
+	}
+	if assertionsEnabled {
+		assert(leafNode != nil, "there always must be some leaf node, not found for key %s", key)
+	}
 	leafNode.insertSorted(key, value)
-	if !leafNode.isOverflow(b.order) {
-		return
+	if leafNode.isOverflow(b.leafOrder) {
+		left, right, median := leafNode.splitAroundMedian(b.allocator)
+		if newRoot := b.replaceNodeWithTwoNodesAndSeparatorRec(leafNode, left, right, median); newRoot != nil {
+			b.root = newRoot
+		}
+	} else {
+		b.updateSummaries(leafNode)
 	}
-	left, right, median := leafNode.splitAroundMedian()
-	if newRoot := b.replaceNodeWithTwoNodesAndSeparatorRec(leafNode, left, right, median); newRoot != nil {
-		b.root = newRoot
+	if b.paranoid != nil {
+		b.paranoidCheck(key)
 	}
 }
 
@@ -117,28 +178,57 @@ func (b *Btree[K, V]) replaceNodeWithTwoNodesAndSeparatorRec(childToRemove, left
 	}
 	parent := childToRemove.getParent()
 	if parent == nil {
-		newParent := &innerNode[K, V]{
-			children:      []node[K, V]{left, right},
-			keys:          []K{separator},
-			accessCounter: b.accessCounter,
-		}
+		newParent := b.newInnerNode()
+		newParent.children = append(newParent.children, left, right)
+		newParent.keys = append(newParent.keys, separator)
 		left.setParent(newParent)
 		right.setParent(newParent)
+		b.resummarize(newParent)
 		return newParent
 	}
-	assert(!parent.isOverflow(b.order), "parent must not be overflow at this point")
+	if assertionsEnabled {
+		assert(!parent.isOverflow(b.order), "parent must not be overflow at this point")
+	}
 	parent.expandAtChild(childToRemove, left, right, separator)
 	left.setParent(parent)
 	right.setParent(parent)
+	b.expandSummaries(parent, left, right)
 	if !parent.isOverflow(b.order) {
+		b.updateSummaries(parent)
 		return nil
 	}
-	newLeft, newRight, newMedian := parent.splitAroundMedian()
-	assert(newLeft.getParent() == nil, "new split left should have nil parent")
-	assert(newRight.getParent() == nil, "new split right should have nil parent")
+	newLeft, newRight, newMedian := parent.splitAroundMedian(b.allocator)
+	b.splitSummaries(parent, newLeft, newRight)
+	if assertionsEnabled {
+		assert(newLeft.getParent() == nil, "new split left should have nil parent")
+		assert(newRight.getParent() == nil, "new split right should have nil parent")
+	}
 	return b.replaceNodeWithTwoNodesAndSeparatorRec(parent, newLeft, newRight, newMedian)
 }
 
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false.
+func (b *Btree[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	b.root.scan(lo, hi, fun)
+}
+
+// Ascend calls fun for all the pairs in ascending order of the keys, until fun returns false. Unlike Scan, it needs no
+// bounds, so it reaches the pairs with the largest possible key.
+func (b *Btree[K, V]) Ascend(fun func(key K, value V) bool) {
+	b.root.runRecursiveUntilError(0, func(level int, n node[K, V]) error {
+		if leaf, ok := n.(leafNode[K, V]); ok {
+			for i := range leaf.len() {
+				if !fun(leaf.keyAt(i), leaf.valueAt(i)) {
+					return errStopped
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// errStopped stops runRecursiveUntilError when the callback of an iteration returns false.
+var errStopped = errors.New("stopped")
+
 func (b *Btree[K, V]) Print(w io.Writer) {
 	b.root.print(w, 0)
 }
@@ -153,12 +243,16 @@ type innerNode[K cmp.Ordered, V any] struct {
 	// keys separate children. For m children there is always m-1 keys.
 	// Key i is the key after child i, like:
 	//   child[0], key[0], child[1], key[1], child[2], key[2], child[3]
-	keys          []K
-	parent        *innerNode[K, V]
+	keys   []K
+	parent *innerNode[K, V]
+	// summaries are the counts and the aggregates of the children, nil unless WithSubtreeCounts is used or the tree is
+	// created with NewWithMonoid.
+	summaries     *childSummaries[V]
+	owner         uint64
 	accessCounter accessCounter
 }
 
-func (n *innerNode[K, V]) findLeafNodeByKey(seekedKey K) *leafNode[K, V] {
+func (n *innerNode[K, V]) findLeafNodeByKey(seekedKey K) leafNode[K, V] {
 	// There must always be at most m (order) children and len(children) - 1 keys that indicate which child
 	// subtree has the keys in specific range. An example:
 	//     0:10      1:20      2:30       -- keys (separators), where in 2:30, the "2" is an index in the array, and "30" is the value.
@@ -168,6 +262,15 @@ func (n *innerNode[K, V]) findLeafNodeByKey(seekedKey K) *leafNode[K, V] {
 	//                     [20, 30) |
 	//                              [30, +inf)
 	n.countAccess()
+	foundNodeIndex := n.childIndex(seekedKey)
+	if assertionsEnabled {
+		assert(foundNodeIndex < len(n.children), "found node index is outside children range")
+	}
+	return n.children[foundNodeIndex].findLeafNodeByKey(seekedKey)
+}
+
+// childIndex returns the index of the child whose range has the key, see findLeafNodeByKey.
+func (n *innerNode[K, V]) childIndex(seekedKey K) int {
 	foundNodeIndex := len(n.keys) // if no key found, use the last range
 	for i, separator := range n.keys {
 		if separator > seekedKey {
@@ -175,9 +278,7 @@ func (n *innerNode[K, V]) findLeafNodeByKey(seekedKey K) *leafNode[K, V] {
 			break
 		}
 	}
-	// Reached the last range.
-	assert(foundNodeIndex < len(n.children), "found node index is outside children range")
-	return n.children[foundNodeIndex].findLeafNodeByKey(seekedKey)
+	return foundNodeIndex
 }
 
 func (n *innerNode[K, V]) isRoot() bool {
@@ -187,7 +288,9 @@ func (n *innerNode[K, V]) isRoot() bool {
 
 func (n *innerNode[K, V]) isOverflow(order int) bool {
 	n.countAccess()
-	assert(len(n.children) <= order+1, "there should be no path that results in child len > one more than order, len(children)=%d, order=%d", len(n.children), order)
+	if assertionsEnabled {
+		assert(len(n.children) <= order+1, "there should be no path that results in child len > one more than order, len(children)=%d, order=%d", len(n.children), order)
+	}
 	return len(n.children) > order
 }
 
@@ -203,6 +306,23 @@ func (n *innerNode[K, V]) expandAtChild(childToRemove, left, right node[K, V], s
 	n.keys = slices.Insert(n.keys, i, separator)
 }
 
+func (n *innerNode[K, V]) scan(lo, hi K, fun func(key K, value V) bool) bool {
+	n.countAccess()
+	for i, child := range n.children {
+		// The child i has the keys in [keys[i-1], keys[i]) range.
+		if i < len(n.keys) && n.keys[i] <= lo {
+			continue
+		}
+		if i > 0 && n.keys[i-1] >= hi {
+			break
+		}
+		if !child.scan(lo, hi, fun) {
+			return false
+		}
+	}
+	return true
+}
+
 func (n *innerNode[K, V]) runRecursiveUntilError(level int, fun func(level int, n node[K, V]) error) error {
 	n.countAccess()
 	if err := fun(level, n); err != nil {
@@ -228,30 +348,30 @@ func (n *innerNode[K, V]) print(w io.Writer, indent int) {
 	fmt.Fprintf(w, "%s--\n", spaces)
 }
 
-func (n *innerNode[K, V]) splitAroundMedian() (*innerNode[K, V], *innerNode[K, V], K) {
+func (n *innerNode[K, V]) splitAroundMedian(allocator *nodeAllocator[K, V]) (*innerNode[K, V], *innerNode[K, V], K) {
 	n.countAccess()
-	assert(slices.IsSorted(n.keys), "expected keys to be sorted, was: %v", n.keys)
+	if assertionsEnabled {
+		assert(slices.IsSorted(n.keys), "expected keys to be sorted, was: %v", n.keys)
+	}
 	iMedian := len(n.keys) / 2
 	medianValue := n.keys[iMedian]
-	leftChildren := slices.Clone(n.children[:iMedian+1]) // clone to allow GC collecting n.children
-	leftKeys := slices.Clone(n.keys[:iMedian])
-	rightChildren := slices.Clone(n.children[iMedian+1:])
-	rightKeys := slices.Clone(n.keys[iMedian+1:])
-	newLeft := &innerNode[K, V]{
-		children:      leftChildren,
-		keys:          leftKeys,
-		accessCounter: n.accessCounter,
-	}
-	for _, c := range leftChildren {
-		c.setParent(newLeft)
-	}
-	newRight := &innerNode[K, V]{
-		children:      rightChildren,
-		keys:          rightKeys,
-		accessCounter: n.accessCounter,
-	}
-	for _, c := range rightChildren {
-		c.setParent(newRight)
+	// copy to new arrays to allow GC collecting n.children
+	newLeft := allocator.newInnerNode(n.accessCounter, n.owner)
+	newLeft.children = append(newLeft.children, n.children[:iMedian+1]...)
+	newLeft.keys = append(newLeft.keys, n.keys[:iMedian]...)
+	newRight := allocator.newInnerNode(n.accessCounter, n.owner)
+	newRight.children = append(newRight.children, n.children[iMedian+1:]...)
+	newRight.keys = append(newRight.keys, n.keys[iMedian+1:]...)
+	// The children shared with a clone are not modified, see Clone.
+	for _, c := range newLeft.children {
+		if ownerOf(c) == n.owner {
+			c.setParent(newLeft)
+		}
+	}
+	for _, c := range newRight.children {
+		if ownerOf(c) == n.owner {
+			c.setParent(newRight)
+		}
 	}
 	return newLeft, newRight, medianValue
 }
@@ -274,11 +394,28 @@ func (n *innerNode[K, V]) countAccess() {
 // Leaf node functions and methods
 ////////////////////////////////////////
 
-// leafNode contains no children, but arbitrary values stored under keys.
-type leafNode[K cmp.Ordered, V any] struct {
-	pairs         []pair[K, V]
-	parent        *innerNode[K, V]
-	accessCounter accessCounter
+// leafNode contains no children, but arbitrary values stored under keys. It is aosLeafNode, soaLeafNode or
+// keysLeafNode, depending on the layout of the tree.
+type leafNode[K cmp.Ordered, V any] interface {
+	node[K, V]
+	getValue(key K) (V, bool)
+	isOverflow(order int) bool
+	// insertSorted adds key and value regardless if this causes overflow or not, or replaces the value if the key is
+	// present.
+	insertSorted(key K, value V)
+	splitAroundMedian(allocator *nodeAllocator[K, V]) (leafNode[K, V], leafNode[K, V], K)
+	// appendPairs adds the pairs after the last pair of the leaf, without keeping the keys sorted.
+	appendPairs(pairs ...pair[K, V])
+	// appendKeys is appendPairs of the keys with the zero values.
+	appendKeys(keys ...K)
+	len() int
+	keyAt(i int) K
+	valueAt(i int) V
+	// bisect returns index of the key equal to seeked key or the first larger than seeked key, or -1 if there is none.
+	bisect(key K) int
+	isSorted() bool
+	setOwner(owner uint64)
+	setAccessCounter(ac accessCounter)
 }
 
 type pair[K any, V any] struct {
@@ -286,22 +423,58 @@ type pair[K any, V any] struct {
 	value V
 }
 
-func newLeafNode[K cmp.Ordered, V any](ac accessCounter) *leafNode[K, V] {
-	return &leafNode[K, V]{
+// newLeafNode returns an empty leaf of the layout.
+func newLeafNode[K cmp.Ordered, V any](ac accessCounter, owner uint64, layout LeafLayout) leafNode[K, V] {
+	switch layout {
+	case LeafLayoutSoA:
+		return &soaLeafNode[K, V]{
+			keys:          []K{},
+			values:        []V{},
+			owner:         owner,
+			accessCounter: ac,
+		}
+	case leafLayoutKeys:
+		return &keysLeafNode[K, V]{
+			keys:          []K{},
+			owner:         owner,
+			accessCounter: ac,
+		}
+	}
+	return &aosLeafNode[K, V]{
 		pairs:         []pair[K, V]{},
+		owner:         owner,
 		accessCounter: ac,
 	}
 }
 
-func (n *leafNode[K, V]) findLeafNodeByKey(seekedKey K) *leafNode[K, V] {
+////////////////////////////////////////
+// AoS leaf node functions and methods
+////////////////////////////////////////
+
+// aosLeafNode is the leaf of LeafLayoutAoS, with the pairs.
+type aosLeafNode[K cmp.Ordered, V any] struct {
+	pairs         []pair[K, V]
+	parent        *innerNode[K, V]
+	owner         uint64
+	accessCounter accessCounter
+}
+
+func (n *aosLeafNode[K, V]) findLeafNodeByKey(seekedKey K) leafNode[K, V] {
 	n.countAccess()
 	return n
 }
 
-func (n *leafNode[K, V]) getValue(key K) (V, bool) {
+func (n *aosLeafNode[K, V]) isRoot() bool {
+	n.countAccess()
+	return n.parent == nil
+}
+
+func (n *aosLeafNode[K, V]) getValue(key K) (V, bool) {
 	n.countAccess()
 	pairs := pairSlice[K, V](n.pairs)
-	assert(pairs.isSorted(), "expected pairs to be sorted")
+	if assertionsEnabled {
+		assert(pairs.isSorted(), "expected pairs to be sorted")
+	}
 	if i := pairs.bisect(key); i == -1 || n.pairs[i].key != key {
 		var zero V
 		return zero, false
@@ -310,67 +483,444 @@ func (n *leafNode[K, V]) getValue(key K) (V, bool) {
 	}
 }
 
-func (n *leafNode[K, V]) isRoot() bool {
+func (n *aosLeafNode[K, V]) isOverflow(order int) bool {
 	n.countAccess()
-	return n.parent == nil
+	return len(n.pairs) > order
 }
 
-func (n *leafNode[K, V]) isOverflow(order int) bool {
+func (n *aosLeafNode[K, V]) insertSorted(key K, value V) {
 	n.countAccess()
-	return len(n.pairs) > order
+	pairs := pairSlice[K, V](n.pairs)
+	if assertionsEnabled {
+		assert(pairs.isSorted(), "pairs should be sorted before insert")
+	}
+	i := pairs.bisect(key)
+	newPair := pair[K, V]{key: key, value: value}
+	if i == -1 {
+		n.pairs = append(n.pairs, newPair)
+	} else if n.pairs[i].key == key {
+		n.pairs[i].value = value
+	} else {
+		n.pairs = slices.Insert(n.pairs, i, newPair)
+	}
+	if assertionsEnabled {
+		assert(pairSlice[K, V](n.pairs).isSorted(), "pairs should be sorted after insert")
+	}
 }
 
-func (n *leafNode[K, V]) getParent() *innerNode[K, V] {
+func (n *aosLeafNode[K, V]) splitAroundMedian(allocator *nodeAllocator[K, V]) (leafNode[K, V], leafNode[K, V], K) {
+	n.countAccess()
+	median := n.medianKey()
+	left := allocator.newLeafNode(n.accessCounter, n.owner, LeafLayoutAoS).(*aosLeafNode[K, V])
+	right := allocator.newLeafNode(n.accessCounter, n.owner, LeafLayoutAoS).(*aosLeafNode[K, V])
+	// The pairs are sorted, so everything before the first key not smaller than the median goes to the left.
+	iMedian := pairSlice[K, V](n.pairs).bisect(median)
+	left.pairs = append(left.pairs, n.pairs[:iMedian]...)
+	right.pairs = append(right.pairs, n.pairs[iMedian:]...)
+	if assertionsEnabled {
+		assert(left.isSorted(), "left should be sorted")
+		assert(right.isSorted(), "right should be sorted")
+	}
+	return left, right, median
+}
+
+func (n *aosLeafNode[K, V]) medianKey() K {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isSorted(), "expected keys to be sorted")
+	}
+	return n.pairs[len(n.pairs)/2].key
+}
+
+func (n *aosLeafNode[K, V]) appendPairs(pairs ...pair[K, V]) {
+	n.pairs = append(n.pairs, pairs...)
+}
+
+func (n *aosLeafNode[K, V]) appendKeys(keys ...K) {
+	n.pairs = slices.Grow(n.pairs, len(keys))
+	for _, key := range keys {
+		n.pairs = append(n.pairs, pair[K, V]{key: key})
+	}
+}
+
+func (n *aosLeafNode[K, V]) len() int {
+	return len(n.pairs)
+}
+
+func (n *aosLeafNode[K, V]) keyAt(i int) K {
+	return n.pairs[i].key
+}
+
+func (n *aosLeafNode[K, V]) valueAt(i int) V {
+	return n.pairs[i].value
+}
+
+func (n *aosLeafNode[K, V]) bisect(key K) int {
+	return pairSlice[K, V](n.pairs).bisect(key)
+}
+
+func (n *aosLeafNode[K, V]) isSorted() bool {
+	return pairSlice[K, V](n.pairs).isSorted()
+}
+
+func (n *aosLeafNode[K, V]) scan(lo, hi K, fun func(key K, value V) bool) bool {
+	n.countAccess()
+	i := n.bisect(lo)
+	if i == -1 {
+		return true
+	}
+	for ; i < len(n.pairs) && n.pairs[i].key < hi; i++ {
+		if !fun(n.pairs[i].key, n.pairs[i].value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *aosLeafNode[K, V]) runRecursiveUntilError(level int, fun func(level int, n node[K, V]) error) error {
+	n.countAccess()
+	if err := fun(level, n); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (n *aosLeafNode[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	for _, p := range n.pairs {
+		fmt.Fprintf(w, "%s[%v]:%v\n", spaces, p.key, p.value)
+	}
+}
+
+func (n *aosLeafNode[K, V]) getParent() *innerNode[K, V] {
 	n.countAccess()
 	return n.parent
 }
 
-func (n *leafNode[K, V]) setParent(p *innerNode[K, V]) {
+func (n *aosLeafNode[K, V]) setParent(p *innerNode[K, V]) {
 	n.countAccess()
 	n.parent = p
 }
 
-// forceAppend adds key and value regardless if this causes overflow or not.
-func (n *leafNode[K, V]) insertSorted(key K, value V) {
+func (n *aosLeafNode[K, V]) setOwner(owner uint64) {
+	n.owner = owner
+}
+
+func (n *aosLeafNode[K, V]) setAccessCounter(ac accessCounter) {
+	n.accessCounter = ac
+}
+
+func (n *aosLeafNode[K, V]) countAccess() {
+	n.accessCounter(n)
+}
+
+////////////////////////////////////////
+// SoA leaf node functions and methods
+////////////////////////////////////////
+
+// soaLeafNode is the leaf of LeafLayoutSoA, with the keys and the values in parallel arrays, values[i] is stored under
+// keys[i].
+type soaLeafNode[K cmp.Ordered, V any] struct {
+	keys          []K
+	values        []V
+	parent        *innerNode[K, V]
+	owner         uint64
+	accessCounter accessCounter
+}
+
+func (n *soaLeafNode[K, V]) findLeafNodeByKey(seekedKey K) leafNode[K, V] {
 	n.countAccess()
-	pairs := pairSlice[K, V](n.pairs)
-	assert(pairs.isSorted(), "pairs should be sorted before insert")
-	i := pairs.bisect(key)
-	newPair := pair[K, V]{key: key, value: value}
-	if i == -1 {
-		n.pairs = append(n.pairs, newPair)
+	return n
+}
+
+func (n *soaLeafNode[K, V]) isRoot() bool {
+	n.countAccess()
+	return n.parent == nil
+}
+
+func (n *soaLeafNode[K, V]) getValue(key K) (V, bool) {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isSorted(), "expected keys to be sorted")
+	}
+	if i := n.bisect(key); i == -1 || n.keys[i] != key {
+		var zero V
+		return zero, false
 	} else {
-		n.pairs = slices.Insert(n.pairs, i, newPair)
+		return n.values[i], true
+	}
+}
+
+func (n *soaLeafNode[K, V]) isOverflow(order int) bool {
+	n.countAccess()
+	return len(n.keys) > order
+}
+
+func (n *soaLeafNode[K, V]) insertSorted(key K, value V) {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isSorted(), "keys should be sorted before insert")
+	}
+	i := n.bisect(key)
+	if i == -1 {
+		i = len(n.keys)
+	} else if n.keys[i] == key {
+		n.values[i] = value
+		return
+	}
+	n.keys = slices.Insert(n.keys, i, key)
+	n.values = slices.Insert(n.values, i, value)
+	if assertionsEnabled {
+		assert(n.isSorted(), "keys should be sorted after insert")
 	}
-	assert(pairSlice[K, V](n.pairs).isSorted(), "pairs should be sorted after insert")
 }
 
-func (n *leafNode[K, V]) splitAroundMedian() (*leafNode[K, V], *leafNode[K, V], K) {
+func (n *soaLeafNode[K, V]) splitAroundMedian(allocator *nodeAllocator[K, V]) (leafNode[K, V], leafNode[K, V], K) {
 	n.countAccess()
 	median := n.medianKey()
-	left, right := newLeafNode[K, V](n.accessCounter), newLeafNode[K, V](n.accessCounter)
-	insertToLeftOrRight := func(p pair[K, V]) {
-		if p.key < median {
-			left.pairs = append(left.pairs, p)
-		} else {
-			right.pairs = append(right.pairs, p)
+	left := allocator.newLeafNode(n.accessCounter, n.owner, LeafLayoutSoA).(*soaLeafNode[K, V])
+	right := allocator.newLeafNode(n.accessCounter, n.owner, LeafLayoutSoA).(*soaLeafNode[K, V])
+	iMedian := n.bisect(median)
+	left.keys = append(left.keys, n.keys[:iMedian]...)
+	left.values = append(left.values, n.values[:iMedian]...)
+	right.keys = append(right.keys, n.keys[iMedian:]...)
+	right.values = append(right.values, n.values[iMedian:]...)
+	if assertionsEnabled {
+		assert(left.isSorted(), "left should be sorted")
+		assert(right.isSorted(), "right should be sorted")
+	}
+	return left, right, median
+}
+
+func (n *soaLeafNode[K, V]) medianKey() K {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isSorted(), "expected keys to be sorted")
+	}
+	return n.keys[len(n.keys)/2]
+}
+
+func (n *soaLeafNode[K, V]) appendPairs(pairs ...pair[K, V]) {
+	n.keys = slices.Grow(n.keys, len(pairs))
+	n.values = slices.Grow(n.values, len(pairs))
+	for _, p := range pairs {
+		n.keys = append(n.keys, p.key)
+		n.values = append(n.values, p.value)
+	}
+}
+
+func (n *soaLeafNode[K, V]) appendKeys(keys ...K) {
+	n.keys = append(n.keys, keys...)
+	n.values = append(n.values, make([]V, len(keys))...)
+}
+
+func (n *soaLeafNode[K, V]) len() int {
+	return len(n.keys)
+}
+
+func (n *soaLeafNode[K, V]) keyAt(i int) K {
+	return n.keys[i]
+}
+
+func (n *soaLeafNode[K, V]) valueAt(i int) V {
+	return n.values[i]
+}
+
+func (n *soaLeafNode[K, V]) bisect(key K) int {
+	i, _ := slices.BinarySearch(n.keys, key)
+	if i == len(n.keys) {
+		return -1
+	}
+	return i
+}
+
+func (n *soaLeafNode[K, V]) isSorted() bool {
+	return slices.IsSorted(n.keys)
+}
+
+func (n *soaLeafNode[K, V]) scan(lo, hi K, fun func(key K, value V) bool) bool {
+	n.countAccess()
+	i := n.bisect(lo)
+	if i == -1 {
+		return true
+	}
+	for ; i < len(n.keys) && n.keys[i] < hi; i++ {
+		if !fun(n.keys[i], n.values[i]) {
+			return false
 		}
 	}
-	for _, p := range n.pairs {
-		insertToLeftOrRight(p)
+	return true
+}
+
+func (n *soaLeafNode[K, V]) runRecursiveUntilError(level int, fun func(level int, n node[K, V]) error) error {
+	n.countAccess()
+	if err := fun(level, n); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (n *soaLeafNode[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	for i, key := range n.keys {
+		fmt.Fprintf(w, "%s[%v]:%v\n", spaces, key, n.values[i])
+	}
+}
+
+func (n *soaLeafNode[K, V]) getParent() *innerNode[K, V] {
+	n.countAccess()
+	return n.parent
+}
+
+func (n *soaLeafNode[K, V]) setParent(p *innerNode[K, V]) {
+	n.countAccess()
+	n.parent = p
+}
+
+func (n *soaLeafNode[K, V]) setOwner(owner uint64) {
+	n.owner = owner
+}
+
+func (n *soaLeafNode[K, V]) setAccessCounter(ac accessCounter) {
+	n.accessCounter = ac
+}
+
+func (n *soaLeafNode[K, V]) countAccess() {
+	n.accessCounter(n)
+}
+
+////////////////////////////////////////
+// Keys leaf node functions and methods
+////////////////////////////////////////
+
+// keysLeafNode is the leaf of the sets, with the keys and no values. The values are always the zero value, so it is
+// only for the trees whose values carry nothing, like struct{} of Set.
+type keysLeafNode[K cmp.Ordered, V any] struct {
+	keys          []K
+	parent        *innerNode[K, V]
+	owner         uint64
+	accessCounter accessCounter
+}
+
+func (n *keysLeafNode[K, V]) findLeafNodeByKey(seekedKey K) leafNode[K, V] {
+	n.countAccess()
+	return n
+}
+
+func (n *keysLeafNode[K, V]) isRoot() bool {
+	n.countAccess()
+	return n.parent == nil
+}
+
+func (n *keysLeafNode[K, V]) getValue(key K) (V, bool) {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isSorted(), "expected keys to be sorted")
+	}
+	var zero V
+	i := n.bisect(key)
+	return zero, i != -1 && n.keys[i] == key
+}
+
+func (n *keysLeafNode[K, V]) isOverflow(order int) bool {
+	n.countAccess()
+	return len(n.keys) > order
+}
+
+func (n *keysLeafNode[K, V]) insertSorted(key K, value V) {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isSorted(), "keys should be sorted before insert")
+	}
+	i := n.bisect(key)
+	if i == -1 {
+		i = len(n.keys)
+	} else if n.keys[i] == key {
+		return
+	}
+	n.keys = slices.Insert(n.keys, i, key)
+	if assertionsEnabled {
+		assert(n.isSorted(), "keys should be sorted after insert")
+	}
+}
+
+func (n *keysLeafNode[K, V]) splitAroundMedian(allocator *nodeAllocator[K, V]) (leafNode[K, V], leafNode[K, V], K) {
+	n.countAccess()
+	median := n.medianKey()
+	left := allocator.newLeafNode(n.accessCounter, n.owner, leafLayoutKeys).(*keysLeafNode[K, V])
+	right := allocator.newLeafNode(n.accessCounter, n.owner, leafLayoutKeys).(*keysLeafNode[K, V])
+	iMedian := n.bisect(median)
+	left.keys = append(left.keys, n.keys[:iMedian]...)
+	right.keys = append(right.keys, n.keys[iMedian:]...)
+	if assertionsEnabled {
+		assert(left.isSorted(), "left should be sorted")
+		assert(right.isSorted(), "right should be sorted")
 	}
-	assert(pairSlice[K, V](left.pairs).isSorted(), "left should be sorted")
-	assert(pairSlice[K, V](right.pairs).isSorted(), "left should be sorted")
 	return left, right, median
 }
 
-func (n *leafNode[K, V]) medianKey() K {
+func (n *keysLeafNode[K, V]) medianKey() K {
 	n.countAccess()
-	assert(pairSlice[K, V](n.pairs).isSorted(), "expecetd keys to be sorted")
-	return n.pairs[len(n.pairs)/2].key
+	if assertionsEnabled {
+		assert(n.isSorted(), "expected keys to be sorted")
+	}
+	return n.keys[len(n.keys)/2]
+}
+
+func (n *keysLeafNode[K, V]) appendPairs(pairs ...pair[K, V]) {
+	n.keys = slices.Grow(n.keys, len(pairs))
+	for _, p := range pairs {
+		n.keys = append(n.keys, p.key)
+	}
 }
 
-func (n *leafNode[K, V]) runRecursiveUntilError(level int, fun func(level int, n node[K, V]) error) error {
+func (n *keysLeafNode[K, V]) appendKeys(keys ...K) {
+	n.keys = append(n.keys, keys...)
+}
+
+func (n *keysLeafNode[K, V]) len() int {
+	return len(n.keys)
+}
+
+func (n *keysLeafNode[K, V]) keyAt(i int) K {
+	return n.keys[i]
+}
+
+func (n *keysLeafNode[K, V]) valueAt(i int) V {
+	var zero V
+	return zero
+}
+
+func (n *keysLeafNode[K, V]) bisect(key K) int {
+	i, _ := slices.BinarySearch(n.keys, key)
+	if i == len(n.keys) {
+		return -1
+	}
+	return i
+}
+
+func (n *keysLeafNode[K, V]) isSorted() bool {
+	return slices.IsSorted(n.keys)
+}
+
+func (n *keysLeafNode[K, V]) scan(lo, hi K, fun func(key K, value V) bool) bool {
+	n.countAccess()
+	i := n.bisect(lo)
+	if i == -1 {
+		return true
+	}
+	var zero V
+	for ; i < len(n.keys) && n.keys[i] < hi; i++ {
+		if !fun(n.keys[i], zero) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *keysLeafNode[K, V]) runRecursiveUntilError(level int, fun func(level int, n node[K, V]) error) error {
 	n.countAccess()
 	if err := fun(level, n); err != nil {
 		return err
@@ -378,15 +928,34 @@ func (n *leafNode[K, V]) runRecursiveUntilError(level int, fun func(level int, n
 	return nil
 }
 
-func (n *leafNode[K, V]) print(w io.Writer, indent int) {
+func (n *keysLeafNode[K, V]) print(w io.Writer, indent int) {
 	n.countAccess()
 	spaces := strings.Repeat(" ", indent)
-	for _, p := range n.pairs {
-		fmt.Fprintf(w, "%s[%v]:%v\n", spaces, p.key, p.value)
+	var zero V
+	for _, key := range n.keys {
+		fmt.Fprintf(w, "%s[%v]:%v\n", spaces, key, zero)
 	}
 }
 
-func (n *leafNode[K, V]) countAccess() {
+func (n *keysLeafNode[K, V]) getParent() *innerNode[K, V] {
+	n.countAccess()
+	return n.parent
+}
+
+func (n *keysLeafNode[K, V]) setParent(p *innerNode[K, V]) {
+	n.countAccess()
+	n.parent = p
+}
+
+func (n *keysLeafNode[K, V]) setOwner(owner uint64) {
+	n.owner = owner
+}
+
+func (n *keysLeafNode[K, V]) setAccessCounter(ac accessCounter) {
+	n.accessCounter = ac
+}
+
+func (n *keysLeafNode[K, V]) countAccess() {
 	n.accessCounter(n)
 }
 
@@ -417,10 +986,11 @@ func (s pairSlice[K, V]) bisect(key K) int {
 	return i
 }
 
-// accessCounter is used to inform that a particular node was accessed for sake of profiling.
-type accessCounter func(n any)
+// accessCounter is used to inform that a particular node was accessed for sake of profiling. It is an alias, so that
+// the methods taking it satisfy interfaces declared with a plain func type.
+type accessCounter = func(n any)
 
 // rebalanceCounter counts number of re-balances of the nodes.
-type rebalanceCounter func()
+type rebalanceCounter = func()
 
 func dummyAccessCounter(n any) {}
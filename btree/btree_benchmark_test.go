@@ -2,47 +2,102 @@ package btree_test
 
 import (
 	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/btree/btreetest"
 	"btree-cache-benchmark/utils"
 	"fmt"
+	"slices"
 	"testing"
+	"unsafe"
 )
 
 const (
-	nValues = 100_000
+	nValues = btreetest.BenchmarkN
 )
 
-const (
-	sequenceTypeRange         = "range"
-	sequenceTypeShuffledRange = "shuffledRange"
-	sequenceTypeRandom        = "random"
+var (
+	orders        = btreetest.BenchmarkOrders
+	sequenceTypes = btreetest.BenchmarkSequenceTypes
 )
 
-var orders []int
-var sequenceTypes []string
+func BenchmarkInsert(t *testing.B) {
+	btreetest.RunInsertBenchmarks(t, newBtree, orders, sequenceTypes)
+}
+
+func BenchmarkInsertFlat(t *testing.B) {
+	btreetest.RunInsertBenchmarks(t, func(order int) btreetest.Tree { return btree.NewFlat[int, int](order) }, orders, sequenceTypes)
+}
+
+// nodeCacheLines are the sizes of nodes in cache lines for -order=auto benchmarks.
+var nodeCacheLines = []int{1, 2, 4, 8}
+
+// BenchmarkInsertAutoOrder reports the orders chosen by AutoOrder, and the sizes of full nodes.
+func BenchmarkInsertAutoOrder(t *testing.B) {
+	for _, lines := range nodeCacheLines {
+		for _, s := range sequenceTypes {
+			r := btree.AutoOrder[int, int](lines)
+			name := fmt.Sprintf("n:%d_order:auto_lines:%d_seq:%s", nValues, lines, s)
+			sequence := btreetest.Sequence(nValues, s)
+			t.Run(name, func(b *testing.B) {
+				reportAutoOrder(b, r)
+				for range b.N {
+					t := btree.New[int, int](r.Order, r.Options()...)
+					for _, value := range sequence {
+						t.Insert(value, value)
+					}
+				}
+			})
+		}
+	}
+}
 
-func init() {
-	orders = []int{2, 3, 6, 10, 23}
-	sequenceTypes = []string{
-		sequenceTypeRange,
-		sequenceTypeShuffledRange,
-		// sequenceTypeRandom,
+func BenchmarkFindAutoOrder(t *testing.B) {
+	for _, lines := range nodeCacheLines {
+		for _, s := range sequenceTypes {
+			r := btree.AutoOrder[int, int](lines)
+			name := fmt.Sprintf("n:%d_order:auto_lines:%d_seq:%s", nValues, lines, s)
+			sequence := btreetest.Sequence(nValues, s)
+			tree := btree.New[int, int](r.Order, r.Options()...)
+			for _, value := range sequence {
+				tree.Insert(value, value)
+			}
+			lookups := slices.Clone(sequence)
+			utils.Shuffle(lookups)
+			t.Run(name, func(b *testing.B) {
+				reportAutoOrder(b, r)
+				for range b.N {
+					for _, value := range lookups {
+						tree.Find(value)
+					}
+				}
+			})
+		}
 	}
 }
 
-func BenchmarkInsert(t *testing.B) {
+func reportAutoOrder(b *testing.B, r btree.AutoOrderResult) {
+	b.ReportMetric(float64(r.Order), "order")
+	b.ReportMetric(float64(r.LeafOrder), "leaf-order")
+	b.ReportMetric(float64(r.InnerNodeBytes), "inner-node-bytes")
+	b.ReportMetric(float64(r.LeafNodeBytes), "leaf-node-bytes")
+}
+
+// BenchmarkInsertMemory reports allocations of trees with and without parent pointers.
+func BenchmarkInsertMemory(t *testing.B) {
 	for _, order := range orders {
 		for _, s := range sequenceTypes {
-			runBenchmarkForInsert(t, s, order)
+			runBenchmarkForInsertMemory(t, s, order, "parent", func() testedTree { return btree.New[int, int](order) })
+			runBenchmarkForInsertMemory(t, s, order, "parentless", func() testedTree { return btree.NewParentless[int, int](order) })
 		}
 	}
 }
 
-func runBenchmarkForInsert(t *testing.B, sequenceType string, order int) {
-	name := fmt.Sprintf("n:%d_order:%d_seq:%s", nValues, order, sequenceType)
-	sequence := getSequence(nValues, sequenceType)
+func runBenchmarkForInsertMemory(t *testing.B, sequenceType string, order int, variant string, newTree func() testedTree) {
+	name := fmt.Sprintf("n:%d_order:%d_seq:%s_variant:%s", nValues, order, sequenceType, variant)
+	sequence := btreetest.Sequence(nValues, sequenceType)
 	t.Run(name, func(b *testing.B) {
+		b.ReportAllocs()
 		for range b.N {
-			t := btree.New[int, int](order)
+			t := newTree()
 			for _, value := range sequence {
 				t.Insert(value, value)
 			}
@@ -50,16 +105,341 @@ func runBenchmarkForInsert(t *testing.B, sequenceType string, order int) {
 	})
 }
 
-func getSequence(n int, t string) []int {
-	switch t {
-	case sequenceTypeRange:
-		return utils.GetSequenceRange(n)
-	case sequenceTypeShuffledRange:
-		s := utils.GetSequenceRange(n)
-		utils.Shuffle(s)
-		return s
-	case sequenceTypeRandom:
-		return utils.GetRandomArray(n)
+// BenchmarkInsertSnapshots compares inserts with no snapshots to inserts with a snapshot taken every few inserts, kept
+// alive until the end, so every insert after a snapshot copies its path.
+func BenchmarkInsertSnapshots(t *testing.B) {
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			sequence := btreetest.Sequence(nValues, s)
+			for _, every := range []int{0, 10_000, 100} {
+				variant := "none"
+				if every > 0 {
+					variant = fmt.Sprintf("every%d", every)
+				}
+				t.Run(fmt.Sprintf("n:%d_order:%d_seq:%s_snapshots:%s", nValues, order, s, variant), func(b *testing.B) {
+					b.ReportAllocs()
+					for range b.N {
+						tree := btree.New[int, int](order)
+						snapshots := []*btree.Btree[int, int]{}
+						for i, value := range sequence {
+							if every > 0 && i%every == 0 {
+								snapshots = append(snapshots, tree.Clone())
+							}
+							tree.Insert(value, value)
+						}
+						b.ReportMetric(float64(len(snapshots)), "snapshots")
+					}
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkRank compares Rank and Select with the subtree counts, in O(log n), to the ones counting the leaves, in
+// O(n).
+func BenchmarkRank(t *testing.B) {
+	for _, order := range orders {
+		for _, counts := range []bool{false, true} {
+			opts := []btree.Option{}
+			if counts {
+				opts = append(opts, btree.WithSubtreeCounts())
+			}
+			tree := btree.New[int, int](order, opts...)
+			sequence := btreetest.Sequence(nValues, btreetest.SequenceShuffledRange)
+			for _, value := range sequence {
+				tree.Insert(value, value)
+			}
+			t.Run(fmt.Sprintf("n:%d_order:%d_counts:%v_op:rank", nValues, order, counts), func(b *testing.B) {
+				for i := range b.N {
+					tree.Rank(sequence[i%len(sequence)])
+				}
+			})
+			t.Run(fmt.Sprintf("n:%d_order:%d_counts:%v_op:select", nValues, order, counts), func(b *testing.B) {
+				for i := range b.N {
+					tree.Select(sequence[i%len(sequence)])
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkAggregate compares the sum of a range of 1% of the keys by Aggregate, using the cached aggregates, to the
+// one by Scan.
+func BenchmarkAggregate(t *testing.B) {
+	sum := btree.Monoid[int]{
+		Identity: 0,
+		Combine:  func(a, b int) int { return a + b },
+		Lift:     func(value int) int { return value },
+	}
+	for _, order := range orders {
+		tree := btree.NewWithMonoid[int](order, sum)
+		sequence := btreetest.Sequence(nValues, btreetest.SequenceShuffledRange)
+		for _, value := range sequence {
+			tree.Insert(value, value)
+		}
+		width := nValues / 100
+		t.Run(fmt.Sprintf("n:%d_order:%d_op:aggregate", nValues, order), func(b *testing.B) {
+			for i := range b.N {
+				lo := sequence[i%len(sequence)]
+				tree.Aggregate(lo, lo+width)
+			}
+		})
+		t.Run(fmt.Sprintf("n:%d_order:%d_op:scan", nValues, order), func(b *testing.B) {
+			for i := range b.N {
+				lo, s := sequence[i%len(sequence)], 0
+				tree.Scan(lo, lo+width, func(key, value int) bool {
+					s += value
+					return true
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkSplitAtJoin splits the tree at a random key and joins the halves back, which copies only the nodes on the
+// path to the key.
+func BenchmarkSplitAtJoin(t *testing.B) {
+	for _, order := range orders {
+		tree := btree.New[int, int](order)
+		sequence := btreetest.Sequence(nValues, btreetest.SequenceShuffledRange)
+		for _, value := range sequence {
+			tree.Insert(value, value)
+		}
+		t.Run(fmt.Sprintf("n:%d_order:%d", nValues, order), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				left, right := tree.SplitAt(sequence[i%len(sequence)])
+				if _, err := btree.Join(left, right); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSetUnion compares Set.Union, a merge and a bulk build, to inserting the keys of one set into a clone of the
+// other, for sets of half overlapping keys. The clone is a bulk build too.
+func BenchmarkSetUnion(t *testing.B) {
+	for _, order := range orders {
+		a, b := btree.NewSet[int](order), btree.NewSet[int](order)
+		for _, value := range btreetest.Sequence(nValues, btreetest.SequenceShuffledRange) {
+			a.Insert(value)
+			b.Insert(value + nValues/2)
+		}
+		t.Run(fmt.Sprintf("n:%d_order:%d_op:merge", nValues, order), func(t *testing.B) {
+			t.ReportAllocs()
+			for range t.N {
+				a.Union(b)
+			}
+		})
+		t.Run(fmt.Sprintf("n:%d_order:%d_op:insert", nValues, order), func(t *testing.B) {
+			t.ReportAllocs()
+			for range t.N {
+				union := a.Clone()
+				b.Ascend(func(key int) bool {
+					union.Insert(key)
+					return true
+				})
+			}
+		})
+	}
+}
+
+// BenchmarkInsertSet reports allocations of a Set, whose leaves store only the keys, and of a Btree with struct{}
+// values in pairs.
+func BenchmarkInsertSet(t *testing.B) {
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			runBenchmarkForInsertMemory(t, s, order, "set", func() testedTree { return setTree{btree.NewSet[int](order)} })
+			runBenchmarkForInsertMemory(t, s, order, "struct", func() testedTree { return structTree{btree.New[int, struct{}](order)} })
+		}
+	}
+}
+
+type setTree struct{ *btree.Set[int] }
+
+func (t setTree) Insert(key, _ int) { t.Set.Insert(key) }
+
+func (t setTree) Find(key int) (int, bool) { return key, t.Contains(key) }
+
+type structTree struct{ *btree.Btree[int, struct{}] }
+
+func (t structTree) Insert(key, _ int) { t.Btree.Insert(key, struct{}{}) }
+
+func (t structTree) Find(key int) (int, bool) {
+	_, ok := t.Btree.Find(key)
+	return key, ok
+}
+
+// BenchmarkIntegrityCheck compares IntegrityCheck on one worker and in parallel, for large trees.
+func BenchmarkIntegrityCheck(t *testing.B) {
+	for _, n := range []int{nValues, 10 * nValues} {
+		tree := btree.New[int, int](10)
+		for _, value := range btreetest.Sequence(n, btreetest.SequenceShuffledRange) {
+			tree.Insert(value, value)
+		}
+		for _, workers := range []int{1, 2, 4, 8} {
+			t.Run(fmt.Sprintf("n:%d_workers:%d", n, workers), func(b *testing.B) {
+				b.ReportAllocs()
+				for range b.N {
+					if err := tree.IntegrityReportParallel(workers).Err(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkFind(t *testing.B) {
+	btreetest.RunFindBenchmarks(t, newBtree, orders, sequenceTypes)
+}
+
+func BenchmarkFindFlat(t *testing.B) {
+	btreetest.RunFindBenchmarks(t, func(order int) btreetest.Tree { return btree.NewFlat[int, int](order) }, orders, sequenceTypes)
+}
+
+// BenchmarkFindRelayout measures Find before relayout (layout:none), and after relayout in each of the orders.
+func BenchmarkFindRelayout(t *testing.B) {
+	layouts := []string{"none", btree.LayoutDFS.String(), btree.LayoutBFS.String(), btree.LayoutLeavesFirst.String()}
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			for _, layout := range layouts {
+				name := fmt.Sprintf("n:%d_order:%d_seq:%s_layout:%s", nValues, order, s, layout)
+				sequence := btreetest.Sequence(nValues, s)
+				tree := btree.New[int, int](order)
+				for _, value := range sequence {
+					tree.Insert(value, value)
+				}
+				if layout != "none" {
+					layoutOrder, err := btree.ParseLayoutOrder(layout)
+					if err != nil {
+						t.Fatal(err)
+					}
+					tree.Relayout(layoutOrder)
+				}
+				lookups := slices.Clone(sequence)
+				utils.Shuffle(lookups)
+				t.Run(name, func(b *testing.B) {
+					for range b.N {
+						for _, value := range lookups {
+							tree.Find(value)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+var allocOrders = []string{"natural", "random", "dfs"}
+
+func BenchmarkInsertAllocOrder(t *testing.B) {
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			for _, allocOrder := range allocOrders {
+				name := fmt.Sprintf("n:%d_order:%d_seq:%s_alloc:%s", nValues, order, s, allocOrder)
+				sequence := btreetest.Sequence(nValues, s)
+				t.Run(name, func(b *testing.B) {
+					for range b.N {
+						newTreeWithAllocOrder(sequence, order, allocOrder)
+					}
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkFindAllocOrder(t *testing.B) {
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			for _, allocOrder := range allocOrders {
+				name := fmt.Sprintf("n:%d_order:%d_seq:%s_alloc:%s", nValues, order, s, allocOrder)
+				sequence := btreetest.Sequence(nValues, s)
+				tree := newTreeWithAllocOrder(sequence, order, allocOrder)
+				lookups := slices.Clone(sequence)
+				utils.Shuffle(lookups)
+				t.Run(name, func(b *testing.B) {
+					for range b.N {
+						for _, value := range lookups {
+							tree.Find(value)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// newTreeWithAllocOrder builds a tree from the sequence, with the nodes placed in memory in the alloc order, like with
+// -alloc-order flag of bree_hist.
+func newTreeWithAllocOrder(sequence []int, order int, allocOrder string) *btree.Btree[int, int] {
+	var opts []btree.Option
+	if allocOrder == "random" {
+		opts = append(opts, btree.WithShuffledAllocation(0))
+	}
+	tree := btree.New[int, int](order, opts...)
+	for _, value := range sequence {
+		tree.Insert(value, value)
+	}
+	if allocOrder == "dfs" {
+		tree.Relayout(btree.LayoutDFS)
+	}
+	return tree
+}
+
+func BenchmarkFindFrozen(t *testing.B) {
+	for _, order := range orders {
+		for _, layout := range []btree.FrozenLayout{btree.FrozenLayoutBFS, btree.FrozenLayoutVEB, btree.FrozenLayoutEytzinger} {
+			name := fmt.Sprintf("n:%d_order:%d_layout:%s", nValues, order, layout)
+			sequence := btreetest.Sequence(nValues, btreetest.SequenceShuffledRange)
+			tree := btree.New[int, int](order)
+			for _, value := range sequence {
+				tree.Insert(value, value)
+			}
+			frozen := tree.Freeze(layout)
+			t.Run(name, func(b *testing.B) {
+				for range b.N {
+					for _, value := range sequence {
+						frozen.Find(value)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkFindLeafLayout compares leaf layouts for values of 8, 64 and 256 bytes.
+func BenchmarkFindLeafLayout(t *testing.B) {
+	for _, order := range orders {
+		for _, layout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA} {
+			runBenchmarkForFindLeafLayout[[1]int64](t, order, layout)
+			runBenchmarkForFindLeafLayout[[8]int64](t, order, layout)
+			runBenchmarkForFindLeafLayout[[32]int64](t, order, layout)
+		}
+	}
+}
+
+func runBenchmarkForFindLeafLayout[V any](t *testing.B, order int, layout btree.LeafLayout) {
+	var value V
+	name := fmt.Sprintf("n:%d_order:%d_layout:%s_vsize:%d", nValues, order, layout, unsafe.Sizeof(value))
+	sequence := btreetest.Sequence(nValues, btreetest.SequenceShuffledRange)
+	tree := btree.New[int, V](order, btree.WithLeafLayout(layout))
+	for _, key := range sequence {
+		tree.Insert(key, value)
+	}
+	t.Run(name, func(b *testing.B) {
+		for range b.N {
+			for _, key := range sequence {
+				tree.Find(key)
+			}
+		}
+	})
+}
+
+// BenchmarkInsertInline runs each inline tree for its own order only.
+func BenchmarkInsertInline(t *testing.B) {
+	for _, tree := range inlineTrees() {
+		btreetest.RunInsertBenchmarks(t, func(int) btreetest.Tree { return tree.newTree() }, []int{tree.order}, sequenceTypes)
 	}
-	panic("bad type: " + t)
 }
@@ -2,154 +2,501 @@ package btree_test
 
 import (
 	"btree-cache-benchmark/btree"
-	"cmp"
+	"btree-cache-benchmark/btree/btreetest"
 	"fmt"
+	"math"
 	"math/rand"
-	"os"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestInsertOne(t *testing.T) {
-	b := btree.New[int, int](2)
-	b.Insert(10, 42)
-	assertFound(t, b, 10, 42)
-	b.Print(os.Stderr)
+func TestConformance(t *testing.T) {
+	btreetest.RunConformance(t, newBtree)
 }
 
-func TestInsertTwoOutOfOrder(t *testing.T) {
-	b := btree.New[int, int](2)
-	b.Insert(20, 120)
-	b.Insert(10, 110)
-	assert.NoError(t, b.IntegrityCheck())
-	assertFound(t, b, 10, 110)
-	assertFound(t, b, 20, 120)
-	b.Print(os.Stderr)
+func TestLeafLayoutSoA(t *testing.T) {
+	btreetest.RunConformance(t, newSoABtree)
 }
-func TestInsertInOrder(t *testing.T) {
-	b := btree.New[int, int](2)
-	b.Insert(10, 110)
-	b.Insert(20, 120)
-	b.Print(os.Stderr)
 
-	assert.NoError(t, b.IntegrityCheck())
-	assertFound(t, b, 10, 110)
-	assertFound(t, b, 20, 120)
+func TestModel(t *testing.T) {
+	btreetest.RunModel(t, newBtree, "btree.New[int, int](%d)")
+	btreetest.RunModel(t, newSoABtree, "btree.New[int, int](%d, btree.WithLeafLayout(btree.LeafLayoutSoA))")
+	// The counts are checked by IntegrityCheck after the clones, the splits and the joins of the model.
+	btreetest.RunModel(t, func(order int) btreetest.Tree {
+		return btree.New[int, int](order, btree.WithSubtreeCounts())
+	}, "btree.New[int, int](%d, btree.WithSubtreeCounts())")
 }
 
-func TestInsertOverOrder(t *testing.T) {
-	b := btree.New[int, int](2)
-	b.Insert(10, 110)
-	b.Insert(20, 120)
-	b.Insert(30, 130)
-	b.Print(os.Stderr)
-	assert.NoError(t, b.IntegrityCheck())
+func FuzzModel(f *testing.F) {
+	btreetest.FuzzModel(f, newBtree, "btree.New[int, int](%d)")
+}
 
-	assertFound(t, b, 10, 110)
-	assertFound(t, b, 20, 120)
-	assertFound(t, b, 30, 130)
+func FuzzModelSoA(f *testing.F) {
+	btreetest.FuzzModel(f, newSoABtree, "btree.New[int, int](%d, btree.WithLeafLayout(btree.LeafLayoutSoA))")
 }
 
-func TestInsertTwiceOverOrder(t *testing.T) {
-	b := btree.New[int, int](2)
-	for _, kv := range [][2]int{
-		{10, 110},
-		{20, 120},
-		{30, 130},
-		{40, 140},
-	} {
+func newBtree(order int) btreetest.Tree {
+	return btree.New[int, int](order)
+}
 
-		b.Insert(kv[0], kv[1])
-		fmt.Fprintf(os.Stderr, "inserted %d\n", kv[0])
-		b.Print(os.Stderr)
+func newSoABtree(order int) btreetest.Tree {
+	return btree.New[int, int](order, btree.WithLeafLayout(btree.LeafLayoutSoA))
+}
+
+func TestShuffledAllocation(t *testing.T) {
+	for _, leafLayout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA} {
+		for _, order := range []int{2, 3, 5, 10} {
+			t.Run(fmt.Sprintf("%s order %d", leafLayout, order), func(t *testing.T) {
+				b := btree.New[int, int](order, btree.WithLeafLayout(leafLayout), btree.WithShuffledAllocation(0))
+				n := 20_000 // enough to use more than one preallocated chunk of leafs
+				for i := range n {
+					b.Insert(i, i)
+				}
+				assert.NoError(t, b.IntegrityCheck())
+				for i := range n {
+					assertFound(t, b, i, i)
+				}
+				assertNotFound(t, b, n)
+			})
+		}
 	}
-	assert.NoError(t, b.IntegrityCheck())
+}
 
-	assertFound(t, b, 10, 110)
-	assertFound(t, b, 20, 120)
-	assertFound(t, b, 30, 130)
-	assertFound(t, b, 40, 140)
+func TestAscend(t *testing.T) {
+	b := btree.New[int, int](3)
+	for _, v := range rand.New(rand.NewSource(0)).Perm(100) {
+		b.Insert(v, v)
+	}
+	b.Insert(math.MaxInt, 0)
+	keys := []int{}
+	b.Ascend(func(key, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Len(t, keys, 101)
+	assert.True(t, slices.IsSorted(keys))
+	assert.Equal(t, math.MaxInt, keys[100])
+
+	keys = []int{}
+	b.Ascend(func(key, value int) bool {
+		keys = append(keys, key)
+		return len(keys) < 3
+	})
+	assert.Equal(t, []int{0, 1, 2}, keys)
 }
 
-func TestInsertThreeTimesOverOrder(t *testing.T) {
-	b := btree.New[int, int](2)
-	for _, kv := range [][2]int{
-		{10, 110},
-		{20, 120},
-		{30, 130},
-		{40, 140},
-		{50, 150},
-	} {
+func TestParanoidChecks(t *testing.T) {
+	for _, level := range []btree.ParanoidLevel{btree.ParanoidLocal, btree.ParanoidFull} {
+		for _, sampleRate := range []float64{0.1, 1} {
+			t.Run(fmt.Sprintf("%s rate %v", level, sampleRate), func(t *testing.T) {
+				b := btree.New[int, int](3, btree.WithParanoidChecks(level, sampleRate))
+				for _, v := range rand.New(rand.NewSource(0)).Perm(1000) {
+					b.Insert(v, v)
+				}
+				assert.NoError(t, b.Err())
+				assert.NoError(t, b.IntegrityCheck())
+			})
+		}
+	}
+}
 
-		b.Insert(kv[0], kv[1])
-		fmt.Fprintf(os.Stderr, "inserted %d\n", kv[0])
-		b.Print(os.Stderr)
+// TestCloneConformance runs the conformance tests on the clones, whose inserts copy the nodes shared with the source.
+func TestCloneConformance(t *testing.T) {
+	btreetest.RunConformance(t, func(order int) btreetest.Tree { return btree.New[int, int](order).Clone() })
+}
+
+func TestClone(t *testing.T) {
+	b := btree.New[int, int](3)
+	perm := rand.New(rand.NewSource(0)).Perm(1000)
+	for _, v := range perm[:500] {
+		b.Insert(v, v)
+	}
+	snapshot := b.Clone()
+	for _, v := range perm {
+		b.Insert(v, -v)
 	}
+	clone := snapshot.Clone()
+	for _, v := range perm[:100] {
+		clone.Insert(v, 2*v)
+	}
+
 	assert.NoError(t, b.IntegrityCheck())
+	assert.NoError(t, snapshot.IntegrityCheck())
+	assert.NoError(t, clone.IntegrityCheck())
+	for i, v := range perm {
+		assertFound(t, b, v, -v)
+		switch {
+		case i < 100:
+			assertFound(t, snapshot, v, v)
+			assertFound(t, clone, v, 2*v)
+		case i < 500:
+			assertFound(t, snapshot, v, v)
+			assertFound(t, clone, v, v)
+		default:
+			assertNotFound(t, snapshot, v)
+			assertNotFound(t, clone, v)
+		}
+	}
+}
 
-	assertFound(t, b, 10, 110)
-	assertFound(t, b, 20, 120)
-	assertFound(t, b, 30, 130)
-	assertFound(t, b, 40, 140)
-	assertFound(t, b, 50, 150)
+// TestCloneConcurrent reads snapshots while the tree is modified by another goroutine, run it with -race.
+func TestCloneConcurrent(t *testing.T) {
+	b := btree.New[int, int](4)
+	n := 10_000
+	snapshots := make(chan *btree.Btree[int, int])
+	go func() {
+		defer close(snapshots)
+		for i := range n {
+			b.Insert(i, i)
+			if i%1000 == 0 {
+				snapshots <- b.Clone()
+			}
+		}
+	}()
+	size := 0
+	for snapshot := range snapshots {
+		size += 1000
+		count := 0
+		snapshot.Ascend(func(key, value int) bool {
+			count++
+			return key == value
+		})
+		assert.Equal(t, size-999, count)
+		assert.NoError(t, snapshot.IntegrityCheck())
+	}
 }
 
-func TestLotsOfSequentialInsertions(t *testing.T) {
-	n := 1000
-	orders := []int{2, 3, 5, 10}
-	for _, order := range orders {
-		order := order
+func TestRank(t *testing.T) {
+	for _, counts := range []bool{false, true} {
+		for _, order := range []int{2, 3, 10} {
+			t.Run(fmt.Sprintf("counts %v order %d", counts, order), func(t *testing.T) {
+				opts := []btree.Option{btree.WithLeafOrder(order + 1)}
+				if counts {
+					opts = append(opts, btree.WithSubtreeCounts())
+				}
+				b := btree.New[int, int](order, opts...)
+				r := rand.New(rand.NewSource(0))
+				keys := []int{}
+				for range 2000 {
+					// Every other key is even, so the odd keys between them are missing, and some keys repeat.
+					key := 2 * r.Intn(1500)
+					if _, ok := b.Find(key); !ok {
+						keys = append(keys, key)
+					}
+					b.Insert(key, -key)
+				}
+				slices.Sort(keys)
+
+				assert.NoError(t, b.IntegrityCheck())
+				assert.Equal(t, len(keys), b.Len())
+				for i, key := range keys {
+					assert.Equal(t, i, b.Rank(key))
+					assert.Equal(t, i+1, b.Rank(key+1))
+					k, v, ok := b.Select(i)
+					assert.True(t, ok)
+					assert.Equal(t, key, k)
+					assert.Equal(t, -key, v)
+				}
+				assert.Equal(t, 0, b.Rank(-1))
+				_, _, ok := b.Select(len(keys))
+				assert.False(t, ok)
+				_, _, ok = b.Select(-1)
+				assert.False(t, ok)
+
+				for range 100 {
+					lo, hi := r.Intn(3000)-10, r.Intn(3000)-10
+					expected := 0
+					b.Scan(lo, hi, func(key, value int) bool {
+						expected++
+						return true
+					})
+					assert.Equal(t, expected, b.CountRange(lo, hi), "range [%d, %d)", lo, hi)
+				}
+
+				b.Insert(-1, 1)
+				b.Relayout(btree.LayoutBFS)
+				b.Insert(-2, 2)
+				assert.NoError(t, b.IntegrityCheck())
+				assert.Equal(t, len(keys)+2, b.Len())
+				k, _, _ := b.Select(1)
+				assert.Equal(t, -1, k)
+			})
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	// The concatenation is not commutative, so the aggregates must keep the order of the keys.
+	concat := btree.Monoid[string]{
+		Identity: "",
+		Combine:  func(a, b string) string { return a + b },
+		Lift:     func(value string) string { return value + "," },
+	}
+	for _, order := range []int{2, 3, 10} {
 		t.Run(fmt.Sprintf("order %d", order), func(t *testing.T) {
-			b := btree.New[int, int](order)
-			for i := range n {
-				b.Insert(i, i)
+			b := btree.NewWithMonoid[int](order, concat, btree.WithLeafOrder(order+1))
+			r := rand.New(rand.NewSource(0))
+			for range 1000 {
+				key := r.Intn(700)
+				b.Insert(key, fmt.Sprint(key, "-", r.Intn(10)))
 			}
-			b.Print(os.Stderr)
-			assert.NoError(t, b.IntegrityCheck())
-			for i := range n {
-				assertFound(t, b, i, i)
+			checkAggregates := func() {
+				assert.NoError(t, b.IntegrityCheck())
+				for range 200 {
+					lo, hi := r.Intn(720)-10, r.Intn(720)-10
+					expected := ""
+					b.Scan(lo, hi, func(key int, value string) bool {
+						expected += value + ","
+						return true
+					})
+					assert.Equal(t, expected, b.Aggregate(lo, hi), "range [%d, %d)", lo, hi)
+				}
 			}
-			assertNotFound(t, b, -1)
-			assertNotFound(t, b, n)
+			checkAggregates()
+			assert.Equal(t, "", b.Aggregate(-2, 0))
+			b.Insert(-1, "x")
+			b.Relayout(btree.LayoutDFS)
+			b.Insert(-2, "y")
+			checkAggregates()
+			assert.Equal(t, "y,x,", b.Aggregate(-2, 0))
 		})
 	}
 }
 
-func TestLotsOfRandomInsertions(t *testing.T) {
+func TestAggregateFloatSum(t *testing.T) {
+	sum := btree.Monoid[float64]{
+		Identity: 0,
+		Combine:  func(a, b float64) float64 { return a + b },
+		Lift:     func(value float64) float64 { return value },
+	}
+	b := btree.NewWithMonoid[int](4, sum)
 	r := rand.New(rand.NewSource(0))
-	values := []int{}
-	for i := range 1000 {
-		values = append(values, i)
+	for range 5000 {
+		b.Insert(r.Intn(3000), r.Float64())
 	}
-	r.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
-	for _, order := range []int{2, 3, 5, 10} {
-		order := order
-		t.Run(fmt.Sprintf("order %d", order), func(t *testing.T) {
-			b := btree.New[int, int](order)
-			for _, v := range values {
+	// The check recomputes the aggregates in the same order as the tree, so even the rounding is the same.
+	assert.NoError(t, b.IntegrityCheck())
+	expected := 0.0
+	b.Ascend(func(key int, value float64) bool {
+		expected += value
+		return true
+	})
+	assert.InDelta(t, expected, b.Aggregate(0, 3000), 1e-9)
+}
+
+func TestSplitAtJoin(t *testing.T) {
+	for _, order := range []int{2, 3, 4, 7} {
+		for _, n := range []int{0, 1, 5, 50, 1000} {
+			t.Run(fmt.Sprintf("order %d n %d", order, n), func(t *testing.T) {
+				r := rand.New(rand.NewSource(int64(n)))
+				b := btree.New[int, int](order)
+				keys := []int{}
+				for _, v := range r.Perm(n) {
+					b.Insert(2*v, 2*v)
+					keys = append(keys, 2*v)
+				}
+				slices.Sort(keys)
+				for _, key := range []int{-1, 0, 1, n / 2, n - 1, n, 2*n - 2, 2*n - 1, 2 * n, r.Intn(2*n + 1)} {
+					left, right := b.SplitAt(key)
+					assert.NoError(t, left.IntegrityCheck(), "left of %d", key)
+					assert.NoError(t, right.IntegrityCheck(), "right of %d", key)
+					i, _ := slices.BinarySearch(keys, key)
+					assert.Equal(t, keys[:i], treeKeys(left))
+					assert.Equal(t, keys[i:], treeKeys(right))
+
+					// The trees share the nodes, but modifying one does not change the others.
+					left.Insert(key-1, 0)
+					right.Insert(key+1, 0)
+					assert.Equal(t, keys, treeKeys(b))
+
+					joined, err := btree.Join(left, right)
+					assert.NoError(t, err)
+					assert.NoError(t, joined.IntegrityCheck())
+					expected := slices.Clone(keys)
+					for _, k := range []int{key - 1, key + 1} {
+						if j, found := slices.BinarySearch(expected, k); !found {
+							expected = slices.Insert(expected, j, k)
+						}
+					}
+					assert.Equal(t, expected, treeKeys(joined))
+					assert.NoError(t, left.IntegrityCheck())
+					assert.NoError(t, right.IntegrityCheck())
+				}
+			})
+		}
+	}
+}
+
+func TestJoinDifferentHeights(t *testing.T) {
+	for _, sizes := range [][2]int{{1, 1000}, {1000, 1}, {3, 200}, {200, 3}, {0, 10}, {10, 0}, {0, 0}} {
+		left, right := btree.New[int, int](3), btree.New[int, int](3)
+		for i := range sizes[0] {
+			left.Insert(i, i)
+		}
+		for i := range sizes[1] {
+			right.Insert(sizes[0]+i, i)
+		}
+		joined, err := btree.Join(left, right)
+		assert.NoError(t, err)
+		assert.NoError(t, joined.IntegrityCheck(), "sizes %v", sizes)
+		assert.Equal(t, sizes[0]+sizes[1], len(treeKeys(joined)), "sizes %v", sizes)
+	}
+}
+
+func TestJoinCountsRebalances(t *testing.T) {
+	left, right := btree.New[int, int](3), btree.New[int, int](3)
+	left.Insert(0, 0)
+	for i := range 200 {
+		right.Insert(1+i, i)
+	}
+	rebalances := 0
+	left.SetRebalanceCounter(func() { rebalances++ })
+	joined, err := btree.Join(left, right)
+	assert.NoError(t, err)
+	assert.Positive(t, rebalances)
+
+	// The result does not modify the nodes it shares with the right tree.
+	joined.Insert(1000, 0)
+	assert.Equal(t, 200, len(treeKeys(right)))
+}
+
+func TestSplitParanoidChecks(t *testing.T) {
+	b := btree.New[int, int](3, btree.WithParanoidChecks(btree.ParanoidFull, 1))
+	for _, v := range rand.New(rand.NewSource(0)).Perm(1000) {
+		b.Insert(v, v)
+	}
+	left, right := b.SplitAt(500)
+	joined, err := btree.Join(left, right)
+	assert.NoError(t, err)
+	b.DeleteRange(100, 200)
+	for _, tree := range []*btree.Btree[int, int]{b, left, right, joined} {
+		assert.NoError(t, tree.Err())
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	left, right := btree.New[int, int](3), btree.New[int, int](3)
+	left.Insert(5, 5)
+	right.Insert(5, 5)
+	_, err := btree.Join(left, right)
+	assert.Error(t, err)
+	_, err = btree.Join(left, btree.New[int, int](4))
+	assert.Error(t, err)
+	_, err = btree.Join(left, btree.New[int, int](3, btree.WithLeafOrder(5)))
+	assert.Error(t, err)
+	_, err = btree.Join(left, btree.New[int, int](3, btree.WithLeafLayout(btree.LeafLayoutSoA)))
+	assert.Error(t, err)
+	_, err = btree.Join(left, btree.New[int, int](3, btree.WithSubtreeCounts()))
+	assert.Error(t, err)
+}
+
+// TestSplitAtJoinSummaries splits and joins the trees with the counts, the aggregates and other leaf orders and layouts,
+// which the integrity check checks the summaries and the parent pointers of.
+func TestSplitAtJoinSummaries(t *testing.T) {
+	sum := btree.Monoid[int]{
+		Identity: 0,
+		Combine:  func(a, b int) int { return a + b },
+		Lift:     func(value int) int { return value },
+	}
+	for _, layout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA} {
+		for _, leafOrder := range []int{2, 3, 8} {
+			opts := []btree.Option{btree.WithSubtreeCounts(), btree.WithLeafLayout(layout), btree.WithLeafOrder(leafOrder)}
+			b := btree.NewWithMonoid[int, int](3, sum, opts...)
+			for _, v := range rand.New(rand.NewSource(0)).Perm(500) {
 				b.Insert(v, v)
 			}
-			b.Print(os.Stderr)
+			for _, key := range []int{0, 1, 100, 250, 499, 500} {
+				left, right := b.SplitAt(key)
+				assert.NoError(t, left.IntegrityCheck(), "left of %d", key)
+				assert.NoError(t, right.IntegrityCheck(), "right of %d", key)
+				assert.Equal(t, key, left.Len())
+				assert.Equal(t, key*(key-1)/2, left.Aggregate(0, 500))
+				assert.Equal(t, 500-key, right.Len())
+				assert.Equal(t, key, left.Rank(key))
+
+				joined, err := btree.Join(left, right)
+				assert.NoError(t, err)
+				assert.NoError(t, joined.IntegrityCheck())
+				assert.Equal(t, 500, joined.Len())
+				assert.Equal(t, 124750, joined.Aggregate(0, 500))
+
+				// The inserts after the surgery keep the summaries up to date on the paths the surgery built.
+				for i := 500; i < 600; i++ {
+					joined.Insert(i, i)
+				}
+				assert.NoError(t, joined.IntegrityCheck())
+				assert.Equal(t, 179700, joined.Aggregate(0, 600))
+			}
+			b.DeleteRange(100, 200)
 			assert.NoError(t, b.IntegrityCheck())
-			assertNotFound(t, b, -1)
-			for _, v := range values {
-				assertFound(t, b, v, v)
+			assert.Equal(t, 400, b.Len())
+			assert.Equal(t, 124750-14950, b.Aggregate(0, 500))
+		}
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	for _, order := range []int{2, 3, 5} {
+		r := rand.New(rand.NewSource(0))
+		b := btree.New[int, int](order)
+		model := map[int]bool{}
+		for range 200 {
+			for range 20 {
+				key := r.Intn(1000)
+				b.Insert(key, key)
+				model[key] = true
 			}
-			assertNotFound(t, b, -1)
-			assertNotFound(t, b, len(values))
-		})
+			lo := r.Intn(1000)
+			hi := lo + r.Intn(50)
+			snapshot := b.Clone()
+			b.DeleteRange(lo, hi)
+			for key := lo; key < hi; key++ {
+				delete(model, key)
+			}
+			if !assert.NoError(t, b.IntegrityCheck()) {
+				return
+			}
+			assert.NoError(t, snapshot.IntegrityCheck())
+			expected := []int{}
+			for key := range model {
+				expected = append(expected, key)
+			}
+			slices.Sort(expected)
+			assert.Equal(t, expected, treeKeys(b))
+			assert.Equal(t, len(expected), b.Len())
+		}
 	}
 }
 
-func assertFound[K cmp.Ordered, V any](t *testing.T, b *btree.Btree[K, V], key K, expected V) {
+// treeKeys returns all the keys of the tree in ascending order.
+func treeKeys(b interface {
+	Ascend(fun func(key, value int) bool)
+}) []int {
+	keys := []int{}
+	b.Ascend(func(key, value int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+func assertFound(t *testing.T, b testedTree, key, expected int) {
 	t.Helper()
 	actual, ok := b.Find(key)
 	assert.True(t, ok, "value not found for key %s", key)
 	assert.Equal(t, expected, actual, "value differs for key %s", key)
 }
 
-func assertNotFound[K cmp.Ordered, V any](t *testing.T, b *btree.Btree[K, V], key K) {
+func assertNotFound(t *testing.T, b testedTree, key int) {
 	_, ok := b.Find(key)
 	assert.False(t, ok, "value found for key %s", key)
 }
+
+// testedTree is implemented by all the B-tree variants, for tests and benchmarks shared between them.
+type testedTree interface {
+	Insert(key, value int)
+	Find(key int) (int, bool)
+	IntegrityCheck() error
+}
@@ -0,0 +1,71 @@
+package btreetest
+
+import (
+	"btree-cache-benchmark/utils"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// BenchmarkN is the number of keys in the benchmarks.
+const BenchmarkN = 100_000
+
+// BenchmarkOrders and BenchmarkSequenceTypes are the dimensions of RunBenchmarks. The random keys are left out, since
+// for the trees they behave like the shuffled range.
+var (
+	BenchmarkOrders        = []int{2, 3, 6, 10, 23}
+	BenchmarkSequenceTypes = []string{SequenceRange, SequenceShuffledRange}
+)
+
+// RunBenchmarks runs the insert and the find benchmarks as sub-benchmarks of b.
+func RunBenchmarks(b *testing.B, factory Factory) {
+	b.Run("Insert", func(b *testing.B) {
+		RunInsertBenchmarks(b, factory, BenchmarkOrders, BenchmarkSequenceTypes)
+	})
+	b.Run("Find", func(b *testing.B) {
+		RunFindBenchmarks(b, factory, BenchmarkOrders, BenchmarkSequenceTypes)
+	})
+}
+
+// RunInsertBenchmarks measures inserting all the keys of the sequence into an empty tree.
+func RunInsertBenchmarks(b *testing.B, factory Factory, orders []int, sequenceTypes []string) {
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			sequence := Sequence(BenchmarkN, s)
+			b.Run(benchmarkName(order, s), func(b *testing.B) {
+				for range b.N {
+					t := factory(order)
+					for _, value := range sequence {
+						t.Insert(value, value)
+					}
+				}
+			})
+		}
+	}
+}
+
+// RunFindBenchmarks builds the tree from the sequence, and then measures finding all the keys in shuffled order.
+func RunFindBenchmarks(b *testing.B, factory Factory, orders []int, sequenceTypes []string) {
+	for _, order := range orders {
+		for _, s := range sequenceTypes {
+			sequence := Sequence(BenchmarkN, s)
+			tree := factory(order)
+			for _, value := range sequence {
+				tree.Insert(value, value)
+			}
+			lookups := slices.Clone(sequence)
+			utils.Shuffle(lookups)
+			b.Run(benchmarkName(order, s), func(b *testing.B) {
+				for range b.N {
+					for _, value := range lookups {
+						tree.Find(value)
+					}
+				}
+			})
+		}
+	}
+}
+
+func benchmarkName(order int, sequenceType string) string {
+	return fmt.Sprintf("n:%d_order:%d_seq:%s", BenchmarkN, order, sequenceType)
+}
@@ -0,0 +1,55 @@
+// Package btreetest is the conformance test suite and the benchmarks shared by all the ordered maps with int keys and
+// values, so any variant of the B-tree, or any other implementation, proves it is correct with one call:
+//
+//	func TestConformance(t *testing.T) {
+//		btreetest.RunConformance(t, func(order int) btreetest.Tree { return btree.New[int, int](order) })
+//	}
+package btreetest
+
+import (
+	"btree-cache-benchmark/utils"
+	"fmt"
+)
+
+// Tree is the ordered map under test. The optional capabilities, IntegrityCheck and Scan, are tested if the tree has
+// them.
+type Tree interface {
+	// Insert replaces the value if the key is already present.
+	Insert(key, value int)
+	Find(key int) (int, bool)
+}
+
+type integrityChecker interface {
+	IntegrityCheck() error
+}
+
+type scanner interface {
+	Scan(lo, hi int, fun func(key, value int) bool)
+}
+
+// Factory creates an empty tree of the order. The trees of a fixed order ignore it.
+type Factory func(order int) Tree
+
+const (
+	SequenceRange         = "range"
+	SequenceShuffledRange = "shuffledRange"
+	SequenceRandom        = "random"
+)
+
+// SequenceTypes are all the sequence types, in the order of the sub-tests.
+var SequenceTypes = []string{SequenceRange, SequenceShuffledRange, SequenceRandom}
+
+// Sequence returns n keys of the sequence type. The random keys are non-negative.
+func Sequence(n int, sequenceType string) []int {
+	switch sequenceType {
+	case SequenceRange:
+		return utils.GetSequenceRange(n)
+	case SequenceShuffledRange:
+		s := utils.GetSequenceRange(n)
+		utils.Shuffle(s)
+		return s
+	case SequenceRandom:
+		return utils.GetRandomArray(n)
+	}
+	panic(fmt.Sprintf("bad sequence type %q", sequenceType))
+}
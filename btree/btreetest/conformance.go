@@ -0,0 +1,186 @@
+package btreetest
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Orders are the orders of the trees in the conformance tests with many keys.
+var Orders = []int{2, 3, 5, 10}
+
+const conformanceN = 1000
+
+// RunConformance runs all the conformance tests as sub-tests of t.
+func RunConformance(t *testing.T, factory Factory) {
+	t.Run("InsertOne", func(t *testing.T) {
+		b := factory(2)
+		b.Insert(10, 42)
+		assertIntegrity(t, b)
+		assertFound(t, b, 10, 42)
+	})
+	// The tests of few keys in a tree of order 2 cover the first splits of the leaves and of the root.
+	for _, tc := range []struct {
+		name string
+		keys []int
+	}{
+		{"InsertTwoOutOfOrder", []int{20, 10}},
+		{"InsertInOrder", []int{10, 20}},
+		{"InsertOverOrder", []int{10, 20, 30}},
+		{"InsertTwiceOverOrder", []int{10, 20, 30, 40}},
+		{"InsertThreeTimesOverOrder", []int{10, 20, 30, 40, 50}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := factory(2)
+			for _, k := range tc.keys {
+				b.Insert(k, 100+k)
+			}
+			assertIntegrity(t, b)
+			for _, k := range tc.keys {
+				assertFound(t, b, k, 100+k)
+			}
+		})
+	}
+	for _, order := range Orders {
+		for _, s := range SequenceTypes {
+			t.Run(fmt.Sprintf("LotsOfInsertions/order_%d/%s", order, s), func(t *testing.T) {
+				keys := Sequence(conformanceN, s)
+				b := factory(order)
+				for _, k := range keys {
+					b.Insert(k, k+1)
+				}
+				assertIntegrity(t, b)
+				for _, k := range keys {
+					assertFound(t, b, k, k+1)
+				}
+				assertNotFound(t, b, -1)
+				if s != SequenceRandom {
+					assertNotFound(t, b, conformanceN)
+				}
+			})
+		}
+	}
+	t.Run("MissingKeys", func(t *testing.T) {
+		b := factory(3)
+		assertNotFound(t, b, 0)
+		for k := 0; k < conformanceN; k += 2 {
+			b.Insert(k, k)
+		}
+		for k := -1; k <= conformanceN; k += 2 {
+			assertNotFound(t, b, k)
+		}
+	})
+	t.Run("Reinsert", func(t *testing.T) {
+		b := factory(3)
+		for k := range 100 {
+			b.Insert(k, k)
+		}
+		for k := range 100 {
+			b.Insert(k, -k)
+		}
+		assertIntegrity(t, b)
+		for k := range 100 {
+			assertFound(t, b, k, -k)
+		}
+		assertScanned(t, b, 100)
+	})
+	// Inserting a key more times than fits a leaf must not split the leaf, which the duplicates of one key would do
+	// around the median equal to all of them.
+	for _, order := range Orders {
+		t.Run(fmt.Sprintf("ReinsertOneKey/order_%d", order), func(t *testing.T) {
+			b := factory(order)
+			for i := range 2 * order {
+				b.Insert(7, i)
+				assertIntegrity(t, b)
+			}
+			assertFound(t, b, 7, 2*order-1)
+			assertScanned(t, b, 1)
+		})
+	}
+	if _, ok := factory(2).(scanner); ok {
+		for _, order := range Orders {
+			t.Run(fmt.Sprintf("Scan/order_%d", order), func(t *testing.T) {
+				runScan(t, factory(order))
+			})
+		}
+	}
+}
+
+func runScan(t *testing.T, b Tree) {
+	keys := Sequence(500, SequenceShuffledRange)
+	for _, k := range keys {
+		b.Insert(k*2, k)
+	}
+	s := b.(scanner)
+	scanned := []int{}
+	s.Scan(101, 201, func(key, value int) bool {
+		assert.Equal(t, key/2, value)
+		scanned = append(scanned, key)
+		return true
+	})
+	expected := []int{}
+	for k := 102; k < 201; k += 2 {
+		expected = append(expected, k)
+	}
+	assert.Equal(t, expected, scanned)
+
+	scanned = []int{}
+	s.Scan(-10, 1000, func(key, value int) bool {
+		scanned = append(scanned, key)
+		return len(scanned) < 3
+	})
+	assert.Equal(t, []int{0, 2, 4}, scanned, "scan should stop when fun returns false")
+
+	scanned = []int{}
+	s.Scan(-10, 1000, func(key, value int) bool {
+		scanned = append(scanned, key)
+		return true
+	})
+	assert.Len(t, scanned, len(keys))
+	assert.True(t, slices.IsSorted(scanned), "scan should be in ascending order")
+
+	scanned = []int{}
+	s.Scan(2000, 3000, func(key, value int) bool {
+		scanned = append(scanned, key)
+		return true
+	})
+	assert.Empty(t, scanned)
+}
+
+func assertIntegrity(t *testing.T, b Tree) {
+	t.Helper()
+	if c, ok := b.(integrityChecker); ok {
+		assert.NoError(t, c.IntegrityCheck())
+	}
+}
+
+// assertScanned checks the number of the pairs of the tree, if it has Scan.
+func assertScanned(t *testing.T, b Tree, expected int) {
+	t.Helper()
+	s, ok := b.(scanner)
+	if !ok {
+		return
+	}
+	n := 0
+	s.Scan(math.MinInt, math.MaxInt, func(key, value int) bool {
+		n++
+		return true
+	})
+	assert.Equal(t, expected, n, "number of the scanned pairs differs")
+}
+
+func assertFound(t *testing.T, b Tree, key, expected int) {
+	t.Helper()
+	actual, ok := b.Find(key)
+	assert.True(t, ok, "value not found for key %d", key)
+	assert.Equal(t, expected, actual, "value differs for key %d", key)
+}
+
+func assertNotFound(t *testing.T, b Tree, key int) {
+	t.Helper()
+	_, ok := b.Find(key)
+	assert.False(t, ok, "value found for key %d", key)
+}
@@ -0,0 +1,474 @@
+package btreetest
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// OpKind is the kind of an operation of a generated case.
+type OpKind int
+
+const (
+	OpInsert OpKind = iota
+	OpFind
+	// OpScan is run only on the trees with Scan.
+	OpScan
+	// OpDelete is run only on the trees with Delete, like the baselines of orderedmap.
+	OpDelete
+	// OpRelayout is run only on the trees with Relayout, and must not change the content of the tree.
+	OpRelayout
+	// OpClone is run only on btree.Btree. It keeps one of the copies as a snapshot, which must not change until the
+	// end of the case, and continues with the other one, the clone if Key is odd.
+	OpClone
+	// OpSplitJoin is run only on btree.Btree. It splits the tree at Key, and continues with the join of the halves.
+	OpSplitJoin
+	// OpDeleteRange is run only on btree.Btree, and deletes the keys in [Key, Hi) range.
+	OpDeleteRange
+)
+
+// Op is one operation of a generated case. Value is the inserted value, Hi and Limit are the upper bound and the
+// maximal number of the pairs of a scan, where 0 means no limit, Hi is also the upper bound of OpDeleteRange, and Key
+// of OpRelayout is the btree.LayoutOrder.
+type Op struct {
+	Kind  OpKind
+	Key   int
+	Value int
+	Hi    int
+	Limit int
+}
+
+// Case is a sequence of operations on a new tree of the order.
+type Case struct {
+	Order int
+	Ops   []Op
+}
+
+type deleter interface {
+	Delete(key int) bool
+}
+
+type relayouter interface {
+	Relayout(order btree.LayoutOrder)
+}
+
+// The mutators of btree.Btree with no counterpart in the other trees.
+type (
+	cloner interface {
+		Clone() *btree.Btree[int, int]
+	}
+	splitter interface {
+		SplitAt(key int) (left, right *btree.Btree[int, int])
+	}
+	rangeDeleter interface {
+		DeleteRange(lo, hi int)
+	}
+)
+
+const bytesPerOp = 3
+
+// DecodeCase generates a case from the bytes, so the same generator serves the random tests and the fuzzing. The first
+// byte is the order, and every next three bytes are an operation. The keys are from -128 to 127, so the operations
+// often hit the keys already in the tree.
+func DecodeCase(data []byte) Case {
+	c := Case{Order: 2}
+	if len(data) == 0 {
+		return c
+	}
+	c.Order = 2 + int(data[0])%9
+	data = data[1:]
+	for i := 0; i+bytesPerOp <= len(data); i += bytesPerOp {
+		kind, a, b := data[i], data[i+1], data[i+2]
+		op := Op{Key: int(int8(a))}
+		switch k := kind % 20; {
+		case k < 8:
+			op.Kind, op.Value = OpInsert, int(b)
+		case k < 12:
+			op.Kind = OpFind
+		case k < 14:
+			op.Kind, op.Hi, op.Limit = OpScan, op.Key+int(b%64), int(b/64)
+		case k < 15:
+			op.Kind = OpDelete
+		case k < 16:
+			op.Kind, op.Key = OpRelayout, int(a%3)
+		case k < 17:
+			op.Kind = OpClone
+		case k < 19:
+			op.Kind = OpSplitJoin
+		default:
+			op.Kind, op.Hi = OpDeleteRange, op.Key+int(b%16)
+		}
+		c.Ops = append(c.Ops, op)
+	}
+	return c
+}
+
+// String returns the operation as a Go statement on the tree b.
+func (op Op) String() string {
+	switch op.Kind {
+	case OpInsert:
+		return fmt.Sprintf("b.Insert(%d, %d)", op.Key, op.Value)
+	case OpFind:
+		return fmt.Sprintf("b.Find(%d)", op.Key)
+	case OpScan:
+		if op.Limit == 0 {
+			return fmt.Sprintf("b.Scan(%d, %d, func(key, value int) bool { return true })", op.Key, op.Hi)
+		}
+		return fmt.Sprintf("n := 0; b.Scan(%d, %d, func(key, value int) bool { n++; return n < %d })", op.Key, op.Hi, op.Limit)
+	case OpDelete:
+		return fmt.Sprintf("b.Delete(%d)", op.Key)
+	case OpRelayout:
+		return fmt.Sprintf("b.Relayout(btree.LayoutOrder(%d)) // %s", op.Key, btree.LayoutOrder(op.Key))
+	case OpClone:
+		if op.Key%2 != 0 {
+			return "b, snapshots = b.Clone(), append(snapshots, b)"
+		}
+		return "snapshots = append(snapshots, b.Clone())"
+	case OpSplitJoin:
+		return fmt.Sprintf("left, right := b.SplitAt(%d); b, _ = btree.Join(left, right)", op.Key)
+	case OpDeleteRange:
+		return fmt.Sprintf("b.DeleteRange(%d, %d)", op.Key, op.Hi)
+	}
+	return fmt.Sprintf("unknown op %d", op.Kind)
+}
+
+// Format returns the case as Go code. The constructor is the Go expression creating the tree, with %d for the order,
+// e.g. "btree.New[int, int](%d)".
+func (c Case) Format(constructor string) string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "b := "+constructor+"\n", c.Order)
+	if slices.ContainsFunc(c.Ops, func(op Op) bool { return op.Kind == OpClone }) {
+		sb.WriteString("snapshots := []*btree.Btree[int, int]{}\n")
+	}
+	for _, op := range c.Ops {
+		if op.Kind == OpScan && op.Limit != 0 || op.Kind == OpSplitJoin {
+			fmt.Fprintf(&sb, "{\n\t%s\n}\n", strings.Replace(op.String(), "; ", "\n\t", 1))
+		} else {
+			fmt.Fprintln(&sb, op.String())
+		}
+	}
+	sb.WriteString("b.IntegrityCheck()\n")
+	return sb.String()
+}
+
+////////////////////////////////////////
+// Model
+////////////////////////////////////////
+
+// model is the reference ordered map, a map and the sorted slice of its keys.
+type model struct {
+	values map[int]int
+	keys   []int
+}
+
+func (m *model) insert(key, value int) {
+	if _, ok := m.values[key]; !ok {
+		i, _ := slices.BinarySearch(m.keys, key)
+		m.keys = slices.Insert(m.keys, i, key)
+	}
+	m.values[key] = value
+}
+
+func (m *model) delete(key int) bool {
+	if _, ok := m.values[key]; !ok {
+		return false
+	}
+	delete(m.values, key)
+	i, _ := slices.BinarySearch(m.keys, key)
+	m.keys = slices.Delete(m.keys, i, i+1)
+	return true
+}
+
+func (m *model) deleteRange(lo, hi int) {
+	i, _ := slices.BinarySearch(m.keys, lo)
+	j, _ := slices.BinarySearch(m.keys, max(lo, hi))
+	for _, key := range m.keys[i:j] {
+		delete(m.values, key)
+	}
+	m.keys = slices.Delete(m.keys, i, j)
+}
+
+func (m *model) clone() *model {
+	c := &model{values: make(map[int]int, len(m.values)), keys: slices.Clone(m.keys)}
+	for key, value := range m.values {
+		c.values[key] = value
+	}
+	return c
+}
+
+func (m *model) scan(lo, hi, limit int) [][2]int {
+	pairs := [][2]int{}
+	for i, _ := slices.BinarySearch(m.keys, lo); i < len(m.keys) && m.keys[i] < hi; i++ {
+		if limit != 0 && len(pairs) == limit {
+			break
+		}
+		pairs = append(pairs, [2]int{m.keys[i], m.values[m.keys[i]]})
+	}
+	return pairs
+}
+
+// CheckModel runs the case on a new tree and on the model. It returns the error of the first operation whose result
+// differs from the model, after which IntegrityCheck fails, or that panics, or of the first snapshot of OpClone that
+// changed by the end of the case. The operations the tree does not support are skipped.
+func CheckModel(factory Factory, c Case) (err error) {
+	step := -1
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("step %d: panic: %v", step, r)
+		}
+	}()
+	r := &modelRun{b: factory(c.Order), m: &model{values: map[int]int{}}}
+	var op Op
+	for step, op = range c.Ops {
+		if err := r.apply(op); err != nil {
+			return fmt.Errorf("step %d: %s: %w", step, op, err)
+		}
+		if err := checkIntegrity(r.b); err != nil {
+			return fmt.Errorf("step %d: %s: integrity check: %w", step, op, err)
+		}
+	}
+	for i, s := range r.snapshots {
+		if err := checkContent(s.b, s.m); err != nil {
+			return fmt.Errorf("snapshot %d: %w", i, err)
+		}
+		if err := checkIntegrity(s.b); err != nil {
+			return fmt.Errorf("snapshot %d: integrity check: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// modelRun is the tree and the model of a case, which OpClone and OpSplitJoin replace, and the snapshots of OpClone
+// with the models of their content.
+type modelRun struct {
+	b         Tree
+	m         *model
+	snapshots []modelRun
+}
+
+func checkIntegrity(b Tree) error {
+	if ic, ok := b.(integrityChecker); ok {
+		return ic.IntegrityCheck()
+	}
+	return nil
+}
+
+// checkContent compares all the pairs of the tree to the model, if the tree has Scan.
+func checkContent(b Tree, m *model) error {
+	s, ok := b.(scanner)
+	if !ok {
+		return nil
+	}
+	pairs := [][2]int{}
+	s.Scan(math.MinInt, math.MaxInt, func(key, value int) bool {
+		pairs = append(pairs, [2]int{key, value})
+		return true
+	})
+	if expected := m.scan(math.MinInt, math.MaxInt, 0); !slices.Equal(pairs, expected) {
+		return fmt.Errorf("got %v, model %v", pairs, expected)
+	}
+	return nil
+}
+
+func (r *modelRun) apply(op Op) error {
+	b, m := r.b, r.m
+	switch op.Kind {
+	case OpInsert:
+		b.Insert(op.Key, op.Value)
+		m.insert(op.Key, op.Value)
+	case OpFind:
+		v, ok := b.Find(op.Key)
+		mv, mok := m.values[op.Key]
+		if ok != mok || v != mv {
+			return fmt.Errorf("got %d, %t, model %d, %t", v, ok, mv, mok)
+		}
+	case OpScan:
+		s, ok := b.(scanner)
+		if !ok {
+			return nil
+		}
+		pairs := [][2]int{}
+		s.Scan(op.Key, op.Hi, func(key, value int) bool {
+			pairs = append(pairs, [2]int{key, value})
+			return op.Limit == 0 || len(pairs) < op.Limit
+		})
+		if expected := m.scan(op.Key, op.Hi, op.Limit); !slices.Equal(pairs, expected) {
+			return fmt.Errorf("got %v, model %v", pairs, expected)
+		}
+	case OpDelete:
+		d, ok := b.(deleter)
+		if !ok {
+			return nil
+		}
+		if got, expected := d.Delete(op.Key), m.delete(op.Key); got != expected {
+			return fmt.Errorf("got %t, model %t", got, expected)
+		}
+	case OpRelayout:
+		if r, ok := b.(relayouter); ok {
+			r.Relayout(btree.LayoutOrder(op.Key))
+		}
+	case OpClone:
+		c, ok := b.(cloner)
+		if !ok {
+			return nil
+		}
+		clone := c.Clone()
+		if op.Key%2 != 0 {
+			r.snapshots = append(r.snapshots, modelRun{b: b, m: m.clone()})
+			r.b = clone
+		} else {
+			r.snapshots = append(r.snapshots, modelRun{b: clone, m: m.clone()})
+		}
+	case OpSplitJoin:
+		s, ok := b.(splitter)
+		if !ok {
+			return nil
+		}
+		left, right := s.SplitAt(op.Key)
+		i, _ := slices.BinarySearch(m.keys, op.Key)
+		if err := checkContent(left, &model{values: m.values, keys: m.keys[:i]}); err != nil {
+			return fmt.Errorf("left: %w", err)
+		}
+		if err := checkContent(right, &model{values: m.values, keys: m.keys[i:]}); err != nil {
+			return fmt.Errorf("right: %w", err)
+		}
+		for _, half := range []Tree{left, right} {
+			if err := checkIntegrity(half); err != nil {
+				return fmt.Errorf("integrity check of a half: %w", err)
+			}
+		}
+		joined, err := btree.Join(left, right)
+		if err != nil {
+			return err
+		}
+		r.b = joined
+	case OpDeleteRange:
+		d, ok := b.(rangeDeleter)
+		if !ok {
+			return nil
+		}
+		d.DeleteRange(op.Key, op.Hi)
+		m.deleteRange(op.Key, op.Hi)
+	}
+	return nil
+}
+
+////////////////////////////////////////
+// Shrinking
+////////////////////////////////////////
+
+// Shrink returns a smaller case that still fails. It removes the operations, lowers the order and simplifies the
+// operations as long as the case keeps failing, so in the result no single such step keeps it failing.
+func Shrink(factory Factory, c Case) Case {
+	fails := func(c Case) bool {
+		return CheckModel(factory, c) != nil
+	}
+	for changed := true; changed; {
+		changed = false
+		for chunk := len(c.Ops) / 2; chunk >= 1; chunk /= 2 {
+			for i := 0; i+chunk <= len(c.Ops); {
+				candidate := Case{Order: c.Order, Ops: slices.Concat(c.Ops[:i], c.Ops[i+chunk:])}
+				if fails(candidate) {
+					c, changed = candidate, true
+				} else {
+					i += chunk
+				}
+			}
+		}
+		for order := 2; order < c.Order; order++ {
+			if candidate := (Case{Order: order, Ops: c.Ops}); fails(candidate) {
+				c, changed = candidate, true
+				break
+			}
+		}
+		for i := range c.Ops {
+			for _, simpler := range simplerOps(c.Ops[i]) {
+				candidate := Case{Order: c.Order, Ops: slices.Clone(c.Ops)}
+				candidate.Ops[i] = simpler
+				if fails(candidate) {
+					c, changed = candidate, true
+					break
+				}
+			}
+		}
+	}
+	return c
+}
+
+// simplerOps returns the variants of the operation with the key, the value, the scan range or the limit closer to 0.
+func simplerOps(op Op) []Op {
+	ops := []Op{}
+	add := func(simpler Op) {
+		if simpler != op {
+			ops = append(ops, simpler)
+		}
+	}
+	withKey := func(key int) Op {
+		s := op
+		s.Hi += key - s.Key // keep the length of the scan range
+		s.Key = key
+		return s
+	}
+	add(withKey(0))
+	add(withKey(op.Key / 2))
+	s := op
+	s.Value = 0
+	add(s)
+	s = op
+	s.Hi = op.Key + (op.Hi-op.Key)/2
+	add(s)
+	s = op
+	s.Limit = 0
+	add(s)
+	return ops
+}
+
+////////////////////////////////////////
+// Test entry points
+////////////////////////////////////////
+
+// ModelCases and ModelOps are the number of the random cases of RunModel, and the number of operations in each.
+var (
+	ModelCases = 200
+	ModelOps   = 200
+)
+
+// RunModel checks random cases against the model. A failing case is shrunk and reported as Go code, see Case.Format
+// for the constructor.
+func RunModel(t *testing.T, factory Factory, constructor string) {
+	t.Helper()
+	r := rand.New(rand.NewSource(0))
+	for i := range ModelCases {
+		data := make([]byte, 1+bytesPerOp*ModelOps)
+		r.Read(data)
+		if err := CheckModel(factory, DecodeCase(data)); err != nil {
+			reportFailure(t, factory, constructor, DecodeCase(data), fmt.Errorf("case %d: %w", i, err))
+		}
+	}
+}
+
+// FuzzModel is RunModel for go test -fuzz. The seed corpus are the first random cases of RunModel.
+func FuzzModel(f *testing.F, factory Factory, constructor string) {
+	r := rand.New(rand.NewSource(0))
+	for range 8 {
+		data := make([]byte, 1+bytesPerOp*ModelOps)
+		r.Read(data)
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckModel(factory, DecodeCase(data)); err != nil {
+			reportFailure(t, factory, constructor, DecodeCase(data), err)
+		}
+	})
+}
+
+func reportFailure(t *testing.T, factory Factory, constructor string, c Case, err error) {
+	t.Helper()
+	shrunk := Shrink(factory, c)
+	t.Fatalf("%v\nshrunk from %d to %d operations, failing with: %v\n%s",
+		err, len(c.Ops), len(shrunk.Ops), CheckModel(factory, shrunk), shrunk.Format(constructor))
+}
@@ -0,0 +1,76 @@
+package btreetest_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/btree/btreetest"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lossyTree loses the value of key 7 once there are more than 20 inserts, to check the harness finds and shrinks it.
+// It hides the mutators of the Btree, like Clone, whose results would not be lossy.
+type lossyTree struct {
+	b       *btree.Btree[int, int]
+	inserts int
+}
+
+func (l *lossyTree) Insert(key, value int) {
+	l.inserts++
+	if key == 7 && l.inserts > 20 {
+		return
+	}
+	l.b.Insert(key, value)
+}
+
+func (l *lossyTree) Find(key int) (int, bool) {
+	return l.b.Find(key)
+}
+
+func (l *lossyTree) Scan(lo, hi int, fun func(key, value int) bool) {
+	l.b.Scan(lo, hi, fun)
+}
+
+func (l *lossyTree) IntegrityCheck() error {
+	return l.b.IntegrityCheck()
+}
+
+func TestShrink(t *testing.T) {
+	factory := func(order int) btreetest.Tree { return &lossyTree{b: btree.New[int, int](order)} }
+	r := rand.New(rand.NewSource(0))
+	var c btreetest.Case
+	for {
+		data := make([]byte, 1+3*200)
+		r.Read(data)
+		if c = btreetest.DecodeCase(data); btreetest.CheckModel(factory, c) != nil {
+			break
+		}
+	}
+	shrunk := btreetest.Shrink(factory, c)
+	assert.Error(t, btreetest.CheckModel(factory, shrunk))
+	// 20 inserts to pass the threshold, the insert of 7 that is lost, and the find or scan that notices it.
+	assert.Len(t, shrunk.Ops, 22)
+	assert.Equal(t, 2, shrunk.Order)
+	last := shrunk.Ops[len(shrunk.Ops)-1]
+	assert.Contains(t, []btreetest.OpKind{btreetest.OpFind, btreetest.OpScan}, last.Kind)
+	assert.Contains(t, shrunk.Format("btree.New[int, int](%d)"), "b := btree.New[int, int](2)\n")
+}
+
+func TestDecodeCase(t *testing.T) {
+	c := btreetest.DecodeCase([]byte{1, 0, 0xff, 5, 8, 3, 0, 12, 10, 200, 15, 4, 0, 16, 3, 0, 17, 4, 0, 19, 250, 20})
+	assert.Equal(t, btreetest.Case{
+		Order: 3,
+		Ops: []btreetest.Op{
+			{Kind: btreetest.OpInsert, Key: -1, Value: 5},
+			{Kind: btreetest.OpFind, Key: 3},
+			{Kind: btreetest.OpScan, Key: 10, Hi: 10 + 200%64, Limit: 200 / 64},
+			{Kind: btreetest.OpRelayout, Key: 1},
+			{Kind: btreetest.OpClone, Key: 3},
+			{Kind: btreetest.OpSplitJoin, Key: 4},
+			{Kind: btreetest.OpDeleteRange, Key: -6, Hi: -2},
+		},
+	}, c)
+	assert.NoError(t, btreetest.CheckModel(func(order int) btreetest.Tree { return btree.New[int, int](order) }, c))
+	assert.Contains(t, c.Format("btree.New[int, int](%d)"), "snapshots := []*btree.Btree[int, int]{}\n")
+}
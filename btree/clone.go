@@ -0,0 +1,104 @@
+package btree
+
+import (
+	"cmp"
+	"sync/atomic"
+)
+
+// Clone makes the nodes copy-on-write. Every node has an owner, the id of the tree that can modify it in place. Clone
+// gives new ids to both copies, so all the nodes become shared, and a tree copies a node it does not own before
+// modifying it. Insert copies the nodes on the path from the root to the leaf, top-down, so the nodes a tree owns are
+// a sub-tree at its root, and the splits propagate up that sub-tree by the parent pointers as before.
+//
+// A shared node may be a child of the nodes of several trees, so its parent pointer is of no use. A tree writes the
+// parent pointers only to the nodes it owns, and only the parent pointers of those are valid, see IntegrityCheck.
+
+// lastOwner is the last id given to a tree.
+var lastOwner atomic.Uint64
+
+func newOwner() uint64 {
+	return lastOwner.Add(1)
+}
+
+// Clone returns a copy of the tree in O(1). The copies share the nodes, so modifying either copy does not change the
+// other one, and one of them can be read while the other one is modified by another goroutine. The copies share the
+// access and the rebalance counters, which must be safe for concurrent use then. The copy has paranoid checks of its
+// own, with no violation found yet, and allocates its nodes on the heap, even with WithShuffledAllocation.
+func (b *Btree[K, V]) Clone() *Btree[K, V] {
+	c := *b
+	b.owner, c.owner = newOwner(), newOwner()
+	b.shared, c.shared = true, true
+	c.allocator = nil
+	if b.paranoid != nil {
+		c.paranoid = newParanoidChecks(b.paranoid.level, b.paranoid.sampleRate)
+	}
+	return &c
+}
+
+// mutableLeaf returns the leaf for the key like findLeafNodeByKey, after replacing the nodes on the path that the tree
+// does not own with their copies, so the path can be modified in place.
+func (b *Btree[K, V]) mutableLeaf(key K) leafNode[K, V] {
+	b.root = b.mutable(b.root, nil)
+	n := b.root
+	for inner, ok := n.(*innerNode[K, V]); ok; inner, ok = n.(*innerNode[K, V]) {
+		inner.countAccess()
+		i := inner.childIndex(key)
+		inner.children[i] = b.mutable(inner.children[i], inner)
+		n = inner.children[i]
+	}
+	n.countAccess()
+	return n.(leafNode[K, V])
+}
+
+// mutable returns the node if the tree owns it, or its copy owned by the tree, with the parent, otherwise. The caller
+// replaces the node with the copy in the parent, which must be owned already. The children of a node the tree does not
+// own are not owned either, so the parent pointers of the children of a copy are left as they are.
+func (b *Btree[K, V]) mutable(n node[K, V], parent *innerNode[K, V]) node[K, V] {
+	if ownerOf(n) == b.owner {
+		return n
+	}
+	n.countAccess()
+	switch t := n.(type) {
+	case *innerNode[K, V]:
+		m := b.newInnerNode()
+		m.children = append(m.children, t.children...)
+		m.keys = append(m.keys, t.keys...)
+		if t.summaries != nil {
+			m.summaries = t.summaries.clone()
+		}
+		m.parent = parent
+		return m
+	case *aosLeafNode[K, V]:
+		m := b.newLeafNode().(*aosLeafNode[K, V])
+		m.pairs = append(m.pairs, t.pairs...)
+		m.parent = parent
+		return m
+	case *soaLeafNode[K, V]:
+		m := b.newLeafNode().(*soaLeafNode[K, V])
+		m.keys = append(m.keys, t.keys...)
+		m.values = append(m.values, t.values...)
+		m.parent = parent
+		return m
+	case *keysLeafNode[K, V]:
+		m := b.newLeafNode().(*keysLeafNode[K, V])
+		m.keys = append(m.keys, t.keys...)
+		m.parent = parent
+		return m
+	}
+	return n
+}
+
+// ownerOf returns the owner of the node, without counting the access.
+func ownerOf[K cmp.Ordered, V any](n node[K, V]) uint64 {
+	switch t := n.(type) {
+	case *innerNode[K, V]:
+		return t.owner
+	case *aosLeafNode[K, V]:
+		return t.owner
+	case *soaLeafNode[K, V]:
+		return t.owner
+	case *keysLeafNode[K, V]:
+		return t.owner
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package btree
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestInsertCopiesPath checks that an insert after Clone copies only the nodes on the path to the leaf.
+func TestInsertCopiesPath(t *testing.T) {
+	for _, layout := range []LeafLayout{LeafLayoutAoS, LeafLayoutSoA, leafLayoutKeys} {
+		b := New[int, int](3, WithLeafLayout(layout), WithSubtreeCounts())
+		for _, v := range rand.New(rand.NewSource(0)).Perm(1000) {
+			b.Insert(v, v)
+		}
+		snapshot := b.Clone()
+		shared := map[node[int, int]]bool{}
+		for _, n := range snapshot.nodesInLayoutOrder(LayoutDFS) {
+			shared[n] = true
+		}
+		// An update of an existing key does not split, so the shape of the tree stays the same.
+		b.Insert(500, -500)
+		copied := 0
+		for _, n := range b.nodesInLayoutOrder(LayoutDFS) {
+			if !shared[n] {
+				copied++
+			}
+		}
+		if height := b.leftmostLeafDepth(); copied != height+1 {
+			t.Errorf("%s: expected %d copied nodes, got %d", layout, height+1, copied)
+		}
+
+		// The nodes on the path are owned by b now, so the next update of the same key copies nothing.
+		before := b.nodesInLayoutOrder(LayoutDFS)
+		b.Insert(500, -501)
+		if !slices.Equal(before, b.nodesInLayoutOrder(LayoutDFS)) {
+			t.Errorf("%s: expected no copied nodes", layout)
+		}
+		if v, _ := snapshot.Find(500); layout != leafLayoutKeys && v != 500 {
+			t.Errorf("%s: snapshot observed the update, got %d", layout, v)
+		}
+		// The splits after the copies keep the parent pointers of the owned nodes, and the counts, of both trees.
+		for i := 1000; i < 1100; i++ {
+			b.Insert(i, i)
+		}
+		if err := b.IntegrityCheck(); err != nil {
+			t.Errorf("%s: %v", layout, err)
+		}
+		if err := snapshot.IntegrityCheck(); err != nil {
+			t.Errorf("%s: %v", layout, err)
+		}
+		if b.Len() != 1100 || snapshot.Len() != 1000 {
+			t.Errorf("%s: expected 1100 and 1000 pairs, got %d and %d", layout, b.Len(), snapshot.Len())
+		}
+
+		// Relayout copies the shared nodes too, so the snapshot owns all its nodes after it.
+		snapshot.Relayout(LayoutDFS)
+		for _, tree := range []*Btree[int, int]{b, snapshot} {
+			if err := tree.IntegrityCheck(); err != nil {
+				t.Errorf("%s: after relayout: %v", layout, err)
+			}
+		}
+		for _, n := range snapshot.nodesInLayoutOrder(LayoutDFS) {
+			if ownerOf(n) != snapshot.owner {
+				t.Fatalf("%s: expected the snapshot to own all its nodes after relayout", layout)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package btree
+
+// The hooks of the fault injection, which is in btree_test, see faultinject_test.go. They expose the nodes of a tree of
+// ints, so the corruptions are written against these few primitives, and not against the internals.
+
+import (
+	"slices"
+	"testing"
+)
+
+// LeafLayoutKeys is the keys-only layout of the leaves of Set.
+const LeafLayoutKeys = leafLayoutKeys
+
+// Node is a node of a tree of ints.
+type Node = node[int, int]
+
+func Root(b *Btree[int, int]) Node {
+	return b.root
+}
+
+func SetRoot(b *Btree[int, int], n Node) {
+	b.root = n
+}
+
+func Order(b *Btree[int, int]) int {
+	return b.order
+}
+
+func LeafOrder(b *Btree[int, int]) int {
+	return b.leafOrder
+}
+
+// Nodes returns the nodes of the tree in the breadth-first order.
+func Nodes(b *Btree[int, int]) []Node {
+	return b.nodesInLayoutOrder(LayoutBFS)
+}
+
+// LeafKeys returns the keys of all the leaves of the tree, in the depth-first order, including the duplicate ones.
+func LeafKeys(b *Btree[int, int]) []int {
+	return leafKeys(b)
+}
+
+func IsLeaf(n Node) bool {
+	_, ok := n.(leafNode[int, int])
+	return ok
+}
+
+// Parent returns the parent of the node, or nil.
+func Parent(n Node) Node {
+	if p := n.getParent(); p != nil {
+		return p
+	}
+	return nil
+}
+
+// SetParent sets the parent of the node to an inner node, or to nil.
+func SetParent(n, parent Node) {
+	p, _ := parent.(*innerNode[int, int])
+	n.setParent(p)
+}
+
+// Children returns the children of an inner node. The slice is the one of the node, so setting a child corrupts it.
+func Children(n Node) []Node {
+	return n.(*innerNode[int, int]).children
+}
+
+// Separators returns the keys of an inner node. The slice is the one of the node.
+func Separators(n Node) []int {
+	return n.(*innerNode[int, int]).keys
+}
+
+// Counts returns the subtree counts of an inner node. The slice is the one of the node.
+func Counts(n Node) []int {
+	return n.(*innerNode[int, int]).summaries.counts
+}
+
+// NewInner returns a new inner node of the tree, with no parent, above the children.
+func NewInner(b *Btree[int, int], children []Node, keys []int) Node {
+	inner := b.allocator.newInnerNode(b.accessCounter, b.owner)
+	inner.children = append(inner.children, children...)
+	inner.keys = append(inner.keys, keys...)
+	for _, c := range children {
+		c.setParent(inner)
+	}
+	return inner
+}
+
+// SplitLeaf splits a leaf around its median, without linking the halves to the parent.
+func SplitLeaf(b *Btree[int, int], n Node) (left, right Node, median int) {
+	return n.(leafNode[int, int]).splitAroundMedian(b.allocator)
+}
+
+// ExpandAtChild replaces a child of an inner node with two nodes, without splitting the inner node.
+func ExpandAtChild(n, child, left, right Node, separator int) {
+	inner := n.(*innerNode[int, int])
+	inner.expandAtChild(child, left, right, separator)
+	left.setParent(inner)
+	right.setParent(inner)
+}
+
+func LeafLen(n Node) int {
+	return n.(leafNode[int, int]).len()
+}
+
+func LeafKey(n Node, i int) int {
+	return n.(leafNode[int, int]).keyAt(i)
+}
+
+func LeafValue(n Node, i int) int {
+	return n.(leafNode[int, int]).valueAt(i)
+}
+
+// SetLeafPair overwrites the i-th pair of a leaf. The keys-only leaf keeps only the key.
+func SetLeafPair(t testing.TB, n Node, i int, key, value int) {
+	t.Helper()
+	switch l := n.(type) {
+	case *aosLeafNode[int, int]:
+		l.pairs[i] = pair[int, int]{key, value}
+	case *soaLeafNode[int, int]:
+		l.keys[i], l.values[i] = key, value
+	case *keysLeafNode[int, int]:
+		l.keys[i] = key
+	default:
+		t.Fatalf("unsupported leaf %T", n)
+	}
+}
+
+// InsertLeafPair inserts a pair at the i-th position of a leaf, regardless of the order and the size of the leaf.
+func InsertLeafPair(t testing.TB, n Node, i int, key, value int) {
+	t.Helper()
+	switch l := n.(type) {
+	case *aosLeafNode[int, int]:
+		l.pairs = slices.Insert(l.pairs, i, pair[int, int]{key, value})
+	case *soaLeafNode[int, int]:
+		l.keys = slices.Insert(l.keys, i, key)
+		l.values = slices.Insert(l.values, i, value)
+	case *keysLeafNode[int, int]:
+		l.keys = slices.Insert(l.keys, i, key)
+	default:
+		t.Fatalf("unsupported leaf %T", n)
+	}
+}
+
+// TruncateLeaf keeps the first size pairs of a leaf.
+func TruncateLeaf(t testing.TB, n Node, size int) {
+	t.Helper()
+	switch l := n.(type) {
+	case *aosLeafNode[int, int]:
+		l.pairs = l.pairs[:size]
+	case *soaLeafNode[int, int]:
+		l.keys, l.values = l.keys[:size], l.values[:size]
+	case *keysLeafNode[int, int]:
+		l.keys = l.keys[:size]
+	default:
+		t.Fatalf("unsupported leaf %T", n)
+	}
+}
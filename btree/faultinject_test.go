@@ -0,0 +1,499 @@
+package btree_test
+
+// The fault injection corrupts a valid tree, to check which corruptions IntegrityCheck reports and Repair fixes. It is in
+// btree_test, and reaches the nodes only through the hooks of export_test.go.
+
+import (
+	"btree-cache-benchmark/btree"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// corruption breaks one invariant of the tree, or returns an error if the tree has no node to corrupt that way.
+type corruption func(t *testing.T, b *btree.Btree[int, int]) error
+
+////////////////////////////////////////
+// Corruptions
+////////////////////////////////////////
+
+// corruptSwapSeparators swaps the first two keys of an inner node.
+func corruptSwapSeparators(t *testing.T, b *btree.Btree[int, int]) error {
+	inner := findInner(b, func(n btree.Node) bool { return len(btree.Separators(n)) >= 2 })
+	if inner == nil {
+		return fmt.Errorf("no inner node with two keys")
+	}
+	keys := btree.Separators(inner)
+	keys[0], keys[1] = keys[1], keys[0]
+	return nil
+}
+
+// corruptLeafKeyOutOfBounds moves the last key of a leaf to the separator right of it, which belongs to the sibling.
+func corruptLeafKeyOutOfBounds(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf, parent, i := findChildLeaf(b, func(n, parent btree.Node, i int) bool {
+		return i < len(btree.Children(parent))-1
+	})
+	if leaf == nil {
+		return fmt.Errorf("no leaf with a right sibling")
+	}
+	last := btree.LeafLen(leaf) - 1
+	btree.SetLeafPair(t, leaf, last, btree.Separators(parent)[i], btree.LeafValue(leaf, last))
+	return nil
+}
+
+// corruptUnsortLeaf swaps the first two pairs of a leaf. The keys stay within the separators.
+func corruptUnsortLeaf(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf := findLeaf(b, func(n btree.Node) bool { return btree.LeafLen(n) >= 2 })
+	if leaf == nil {
+		return fmt.Errorf("no leaf with two pairs")
+	}
+	k0, v0 := btree.LeafKey(leaf, 0), btree.LeafValue(leaf, 0)
+	k1, v1 := btree.LeafKey(leaf, 1), btree.LeafValue(leaf, 1)
+	btree.SetLeafPair(t, leaf, 0, k1, v1)
+	btree.SetLeafPair(t, leaf, 1, k0, v0)
+	return nil
+}
+
+// corruptDuplicateKey repeats the first pair of a leaf, so the leaf stays sorted, but not strictly.
+func corruptDuplicateKey(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf := findLeaf(b, func(n btree.Node) bool {
+		return btree.LeafLen(n) >= 1 && btree.LeafLen(n) < btree.LeafOrder(b)
+	})
+	if leaf == nil {
+		return fmt.Errorf("no leaf with room for a pair")
+	}
+	btree.InsertLeafPair(t, leaf, 0, btree.LeafKey(leaf, 0), btree.LeafValue(leaf, 0))
+	return nil
+}
+
+// corruptNilParent clears the parent pointer of a leaf.
+func corruptNilParent(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf := findLeaf(b, func(n btree.Node) bool { return btree.Parent(n) != nil })
+	if leaf == nil {
+		return fmt.Errorf("no leaf with a parent")
+	}
+	btree.SetParent(leaf, nil)
+	return nil
+}
+
+// corruptWrongParent points the parent pointer of a leaf to another inner node.
+func corruptWrongParent(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf := findLeaf(b, func(n btree.Node) bool {
+		return btree.Parent(n) != nil && btree.Parent(n) != btree.Root(b)
+	})
+	if leaf == nil {
+		return fmt.Errorf("no leaf with a non-root parent")
+	}
+	btree.SetParent(leaf, btree.Root(b))
+	return nil
+}
+
+// corruptRootParent gives the root a parent.
+func corruptRootParent(t *testing.T, b *btree.Btree[int, int]) error {
+	inner := findInner(b, func(n btree.Node) bool { return n != btree.Root(b) })
+	if inner == nil {
+		return fmt.Errorf("no non-root inner node")
+	}
+	btree.SetParent(btree.Root(b), inner)
+	return nil
+}
+
+// corruptLeafDepth moves a leaf one level deeper, splitting it under a new inner node, so all the keys stay in place.
+func corruptLeafDepth(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf, parent, i := findChildLeaf(b, func(n, parent btree.Node, i int) bool { return btree.LeafLen(n) >= 2 })
+	if leaf == nil {
+		return fmt.Errorf("no leaf with a parent and two pairs")
+	}
+	left, right, median := btree.SplitLeaf(b, leaf)
+	inner := btree.NewInner(b, []btree.Node{left, right}, []int{median})
+	btree.SetParent(inner, parent)
+	btree.Children(parent)[i] = inner
+	return nil
+}
+
+// corruptOverfillLeaf appends pairs to the rightmost leaf past the leaf order, without splitting it.
+func corruptOverfillLeaf(t *testing.T, b *btree.Btree[int, int]) error {
+	rightmost := btree.Root(b)
+	for !btree.IsLeaf(rightmost) {
+		children := btree.Children(rightmost)
+		rightmost = children[len(children)-1]
+	}
+	for key := btree.LeafKey(rightmost, btree.LeafLen(rightmost)-1) + 1; btree.LeafLen(rightmost) <= btree.LeafOrder(b); key++ {
+		btree.InsertLeafPair(t, rightmost, btree.LeafLen(rightmost), key, key)
+	}
+	return nil
+}
+
+// corruptOverfillInner splits the leaves of an inner node past the order, without splitting the inner node.
+func corruptOverfillInner(t *testing.T, b *btree.Btree[int, int]) error {
+	inner := findInner(b, func(n btree.Node) bool { return btree.IsLeaf(btree.Children(n)[0]) })
+	if inner == nil {
+		return fmt.Errorf("no inner node above leaves")
+	}
+	for len(btree.Children(inner)) <= btree.Order(b) {
+		i := slices.IndexFunc(btree.Children(inner), func(c btree.Node) bool { return btree.LeafLen(c) >= 2 })
+		if i == -1 {
+			return fmt.Errorf("no leaf with two pairs to split")
+		}
+		leaf := btree.Children(inner)[i]
+		left, right, median := btree.SplitLeaf(b, leaf)
+		btree.ExpandAtChild(inner, leaf, left, right, median)
+	}
+	return nil
+}
+
+// corruptUnderfillLeaf leaves a single pair in a non-root leaf.
+func corruptUnderfillLeaf(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf := findLeaf(b, func(n btree.Node) bool { return btree.Parent(n) != nil && btree.LeafLen(n) >= 2 })
+	if leaf == nil {
+		return fmt.Errorf("no leaf with a parent and two pairs")
+	}
+	btree.TruncateLeaf(t, leaf, 1)
+	return nil
+}
+
+// corruptEmptyLeaf removes all the pairs of a non-root leaf.
+func corruptEmptyLeaf(t *testing.T, b *btree.Btree[int, int]) error {
+	leaf := findLeaf(b, func(n btree.Node) bool { return btree.Parent(n) != nil })
+	if leaf == nil {
+		return fmt.Errorf("no leaf with a parent")
+	}
+	btree.TruncateLeaf(t, leaf, 0)
+	return nil
+}
+
+// corruptRootWithOneChild puts a new root with the old root as its only child above the tree.
+func corruptRootWithOneChild(t *testing.T, b *btree.Btree[int, int]) error {
+	btree.SetRoot(b, btree.NewInner(b, []btree.Node{btree.Root(b)}, nil))
+	return nil
+}
+
+////////////////////////////////////////
+// Helpers
+////////////////////////////////////////
+
+func findInner(b *btree.Btree[int, int], pred func(n btree.Node) bool) btree.Node {
+	for _, n := range btree.Nodes(b) {
+		if !btree.IsLeaf(n) && pred(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+func findLeaf(b *btree.Btree[int, int], pred func(n btree.Node) bool) btree.Node {
+	for _, n := range btree.Nodes(b) {
+		if btree.IsLeaf(n) && pred(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// findChildLeaf returns the first leaf, in the breadth-first order, for which pred is true, with its parent and its
+// index among the children of the parent, or nil if there is none. The root is not considered.
+func findChildLeaf(b *btree.Btree[int, int], pred func(n, parent btree.Node, i int) bool) (btree.Node, btree.Node, int) {
+	for _, n := range btree.Nodes(b) {
+		if !btree.IsLeaf(n) {
+			for i, c := range btree.Children(n) {
+				if btree.IsLeaf(c) && pred(c, n, i) {
+					return c, n, i
+				}
+			}
+		}
+	}
+	return nil, nil, 0
+}
+
+////////////////////////////////////////
+// Matrix
+////////////////////////////////////////
+
+// corruptions is the matrix of the corruptions and the kinds of violations IntegrityCheck reports for them.
+var corruptions = []struct {
+	name     string
+	corrupt  corruption
+	expected error
+}{
+	{"swap separators", corruptSwapSeparators, btree.ErrSeparatorOrder},
+	{"leaf key out of bounds", corruptLeafKeyOutOfBounds, btree.ErrSeparatorBounds},
+	{"unsort leaf", corruptUnsortLeaf, btree.ErrKeyOrder},
+	{"duplicate key", corruptDuplicateKey, btree.ErrDuplicateKey},
+	{"nil parent", corruptNilParent, btree.ErrParent},
+	{"wrong parent", corruptWrongParent, btree.ErrParent},
+	{"root parent", corruptRootParent, btree.ErrRootParent},
+	{"leaf depth", corruptLeafDepth, btree.ErrLeafDepth},
+	{"overfill leaf", corruptOverfillLeaf, btree.ErrOverflow},
+	{"overfill inner", corruptOverfillInner, btree.ErrOverflow},
+	{"underfill leaf", corruptUnderfillLeaf, btree.ErrUnderflow},
+	{"empty leaf", corruptEmptyLeaf, btree.ErrUnderflow},
+	{"root with one child", corruptRootWithOneChild, btree.ErrRootChildren},
+}
+
+func TestIntegrityCheckDetectsCorruption(t *testing.T) {
+	for _, layout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA, btree.LeafLayoutKeys} {
+		for _, tc := range corruptions {
+			t.Run(fmt.Sprintf("%s/%s", layout, tc.name), func(t *testing.T) {
+				b := newCorruptibleTree(layout)
+				if err := b.IntegrityCheck(); err != nil {
+					t.Fatalf("tree corrupted before the corruption: %v", err)
+				}
+				if err := tc.corrupt(t, b); err != nil {
+					t.Fatal(err)
+				}
+				if err := b.IntegrityCheck(); !errors.Is(err, tc.expected) {
+					t.Errorf("expected %v, got %v", tc.expected, err)
+				}
+				if sequential, parallel := b.IntegrityReportParallel(1), b.IntegrityReportParallel(4); !reflect.DeepEqual(sequential, parallel) {
+					t.Errorf("parallel report differs, sequential: %v, parallel: %v", sequential, parallel)
+				}
+			})
+		}
+	}
+}
+
+func TestIntegrityReport(t *testing.T) {
+	b := newCorruptibleTree(btree.LeafLayoutAoS)
+	if err := corruptUnderfillLeaf(t, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := corruptRootParent(t, b); err != nil {
+		t.Fatal(err)
+	}
+	report := b.IntegrityReport()
+	if len(report.Violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", report)
+	}
+	if v := report.Violations[0]; v.Err != btree.ErrRootParent || v.Level != 0 || len(v.Path) != 0 {
+		t.Errorf("expected root parent violation at the root, got %v", v)
+	}
+
+	var v *btree.Violation[int]
+	if !errors.As(b.IntegrityCheck(), &v) || v.Err != btree.ErrRootParent {
+		t.Errorf("expected the first violation, got %v", v)
+	}
+	v = report.Violations[1]
+	if v.Err != btree.ErrUnderflow || v.Level != len(v.Path) {
+		t.Fatalf("expected underflow violation, got %v", v)
+	}
+	n := btree.Root(b)
+	for _, i := range v.Path {
+		n = btree.Children(n)[i]
+	}
+	if !btree.IsLeaf(n) || btree.LeafLen(n) != 1 {
+		t.Errorf("expected the path to lead to the underfull leaf, got %v", n)
+	}
+}
+
+// TestIntegrityReportCountsNothing runs the parallel check with an access counter that is not safe for concurrent use,
+// so the race detector reports any access counted by the workers.
+func TestIntegrityReportCountsNothing(t *testing.T) {
+	b := btree.New[int, int](3)
+	accesses := 0
+	b.SetAccessCounter(func(any) { accesses++ })
+	for i := range 2000 {
+		b.Insert(i, i)
+	}
+	accesses = 0
+	if err := b.IntegrityReportParallel(4).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if accesses != 0 {
+		t.Errorf("expected no accesses counted, got %d", accesses)
+	}
+}
+
+func TestIntegrityCheckSubtreeCounts(t *testing.T) {
+	for _, layout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA, btree.LeafLayoutKeys} {
+		for _, inner := range []func(b *btree.Btree[int, int]) btree.Node{
+			btree.Root,
+			func(b *btree.Btree[int, int]) btree.Node {
+				return findInner(b, func(n btree.Node) bool { return btree.IsLeaf(btree.Children(n)[0]) })
+			},
+		} {
+			b := newCorruptibleTree(layout, btree.WithSubtreeCounts())
+			if err := b.IntegrityCheck(); err != nil {
+				t.Fatal(err)
+			}
+			btree.Counts(inner(b))[1]++
+			err := b.IntegrityCheck()
+			if !errors.Is(err, btree.ErrSubtreeCount) {
+				t.Errorf("expected %v, got %v", btree.ErrSubtreeCount, err)
+			}
+			if !reflect.DeepEqual(b.IntegrityReportParallel(1), b.IntegrityReportParallel(4)) {
+				t.Errorf("expected the same report for any number of workers")
+			}
+			if report := b.Repair(); len(report.Violations) != 1 {
+				t.Errorf("expected one violation, got %v", report.Violations)
+			}
+			if err := b.IntegrityCheck(); err != nil {
+				t.Errorf("repaired tree is not valid: %v", err)
+			}
+		}
+	}
+}
+
+// TestIntegrityCheckAggregates does not run on the keys-only leaves, which have no values to aggregate.
+func TestIntegrityCheckAggregates(t *testing.T) {
+	for _, layout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA} {
+		sum := btree.Monoid[int]{
+			Identity: 0,
+			Combine:  func(a, b int) int { return a + b },
+			Lift:     func(value int) int { return value },
+		}
+		b := btree.NewWithMonoid[int, int](3, sum, btree.WithLeafLayout(layout))
+		for _, v := range rand.New(rand.NewSource(0)).Perm(100) {
+			b.Insert(v, v)
+		}
+		if err := b.IntegrityCheck(); err != nil {
+			t.Fatal(err)
+		}
+		if sum := b.Aggregate(0, 100); sum != 4950 {
+			t.Errorf("expected sum 4950, got %d", sum)
+		}
+		leaf, _, _ := findChildLeaf(b, func(btree.Node, btree.Node, int) bool { return true })
+		btree.SetLeafPair(t, leaf, 0, btree.LeafKey(leaf, 0), -1)
+		if err := b.IntegrityCheck(); !errors.Is(err, btree.ErrAggregate) {
+			t.Errorf("expected %v, got %v", btree.ErrAggregate, err)
+		}
+		if !reflect.DeepEqual(b.IntegrityReportParallel(1), b.IntegrityReportParallel(4)) {
+			t.Errorf("expected the same report for any number of workers")
+		}
+		b.Repair()
+		if err := b.IntegrityCheck(); err != nil {
+			t.Errorf("repaired tree is not valid: %v", err)
+		}
+	}
+}
+
+// TestParanoidChecksCorruption corrupts the leftmost leaf, and inserts to the rightmost leaf, which only the full check notices,
+// and then updates the smallest key, in the leftmost leaf, without splitting it.
+func TestParanoidChecksCorruption(t *testing.T) {
+	testCases := []struct {
+		level      btree.ParanoidLevel
+		sampleRate float64
+		// expectedRight and expectedLeft are the errors after the inserts to the rightmost and leftmost leaf.
+		expectedRight, expectedLeft error
+	}{
+		{btree.ParanoidLocal, 1, nil, btree.ErrSeparatorBounds},
+		{btree.ParanoidFull, 1, btree.ErrSeparatorBounds, btree.ErrSeparatorBounds},
+		{btree.ParanoidFull, 0, nil, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s rate %v", tc.level, tc.sampleRate), func(t *testing.T) {
+			b := newCorruptibleTree(btree.LeafLayoutAoS, btree.WithParanoidChecks(tc.level, tc.sampleRate))
+			if err := b.Err(); err != nil {
+				t.Fatalf("tree corrupted before the corruption: %v", err)
+			}
+			if err := corruptLeafKeyOutOfBounds(t, b); err != nil {
+				t.Fatal(err)
+			}
+			b.Insert(1000, 1000)
+			if err := b.Err(); !errorIs(err, tc.expectedRight) {
+				t.Errorf("after the insert to the rightmost leaf, expected %v, got %v", tc.expectedRight, err)
+			}
+			b.Insert(0, 0)
+			if err := b.Err(); !errorIs(err, tc.expectedLeft) {
+				t.Errorf("after the insert to the leftmost leaf, expected %v, got %v", tc.expectedLeft, err)
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	for _, layout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA, btree.LeafLayoutKeys} {
+		for _, tc := range corruptions {
+			t.Run(fmt.Sprintf("%s/%s", layout, tc.name), func(t *testing.T) {
+				b := newCorruptibleTree(layout)
+				if err := tc.corrupt(t, b); err != nil {
+					t.Fatal(err)
+				}
+				expectedKeys := btree.LeafKeys(b)
+				slices.Sort(expectedKeys)
+				expectedKeys = slices.Compact(expectedKeys)
+
+				report := b.Repair()
+				if len(report.Violations) == 0 {
+					t.Error("expected the violations before the repair")
+				}
+				if err := b.IntegrityCheck(); err != nil {
+					t.Fatalf("repaired tree is not valid: %v", err)
+				}
+				keys := []int{}
+				b.Ascend(func(key, value int) bool {
+					// All the corruptions keep the values equal to the keys, except the pairs out of the bounds. The
+					// keys-only leaves have no values.
+					if layout != btree.LeafLayoutKeys && key != value {
+						t.Errorf("expected value %d, got %d", key, value)
+					}
+					keys = append(keys, key)
+					return true
+				})
+				if !slices.Equal(expectedKeys, keys) {
+					t.Errorf("expected keys %v, got %v", expectedKeys, keys)
+				}
+				if report.Pairs != len(keys) {
+					t.Errorf("expected %d pairs in the report, got %d", len(keys), report.Pairs)
+				}
+			})
+		}
+	}
+}
+
+func TestRepairReport(t *testing.T) {
+	b := newCorruptibleTree(btree.LeafLayoutAoS)
+	if err := corruptDuplicateKey(t, b); err != nil {
+		t.Fatal(err)
+	}
+	if report := b.Repair(); len(report.Dropped) != 1 || len(report.Moved) != 0 {
+		t.Errorf("expected one dropped key, got %+v", report)
+	}
+
+	b = newCorruptibleTree(btree.LeafLayoutAoS)
+	if err := corruptSwapSeparators(t, b); err != nil {
+		t.Fatal(err)
+	}
+	if report := b.Repair(); len(report.Dropped) != 0 || len(report.Moved) == 0 {
+		t.Errorf("expected moved keys, got %+v", report)
+	}
+
+	// A cycle, the first child of an inner node above the leaves is the root.
+	b = newCorruptibleTree(btree.LeafLayoutAoS)
+	inner := findInner(b, func(n btree.Node) bool { return btree.IsLeaf(btree.Children(n)[0]) })
+	btree.Children(inner)[0] = btree.Root(b)
+	if err := b.IntegrityCheck(); !errors.Is(err, btree.ErrLeafDepth) {
+		t.Errorf("expected %v, got %v", btree.ErrLeafDepth, err)
+	}
+	if report := b.Repair(); report.SharedNodes != 1 {
+		t.Errorf("expected one shared node, got %+v", report)
+	}
+	if err := b.IntegrityCheck(); err != nil {
+		t.Errorf("repaired tree is not valid: %v", err)
+	}
+
+	b = newCorruptibleTree(btree.LeafLayoutAoS)
+	root := btree.Root(b)
+	if report := b.Repair(); len(report.Violations) != 0 || btree.Root(b) != root {
+		t.Errorf("expected no repair of a valid tree, got %+v", report)
+	}
+}
+
+// errorIs is errors.Is, where nil target matches only nil error.
+func errorIs(err, target error) bool {
+	if target == nil {
+		return err == nil
+	}
+	return errors.Is(err, target)
+}
+
+// newCorruptibleTree returns a tree of order 3 with several levels of inner nodes, so every corruption finds its node.
+func newCorruptibleTree(layout btree.LeafLayout, opts ...btree.Option) *btree.Btree[int, int] {
+	b := btree.New[int, int](3, append(opts, btree.WithLeafLayout(layout))...)
+	for _, v := range rand.New(rand.NewSource(0)).Perm(100) {
+		b.Insert(v, v)
+	}
+	return b
+}
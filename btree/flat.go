@@ -0,0 +1,324 @@
+package btree
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// FlatBtree is a B-tree with the same algorithm as Btree, but where inner nodes and leafs are one concrete struct
+// tagged with isLeaf. The descent has no dynamic dispatch, compared to Btree that calls node interface on every level.
+type FlatBtree[K cmp.Ordered, V any] struct {
+	// The maximum number of child nodes of a node.
+	order            int
+	root             *flatNode[K, V]
+	accessCounter    accessCounter
+	rebalanceCounter rebalanceCounter
+}
+
+// flatNode is either an inner node or a leaf node, depending on isLeaf.
+type flatNode[K cmp.Ordered, V any] struct {
+	isLeaf bool
+	// keys separate children of an inner node, like in innerNode. Unused in leafs.
+	keys []K
+	// children of an inner node. Unused in leafs.
+	children []*flatNode[K, V]
+	// pairs of a leaf node, like in aosLeafNode. Unused in inner nodes.
+	pairs         []pair[K, V]
+	parent        *flatNode[K, V]
+	accessCounter accessCounter
+}
+
+////////////////////////////////////////
+// FlatBtree functions and methods
+////////////////////////////////////////
+
+func NewFlat[K cmp.Ordered, V any](order int) *FlatBtree[K, V] {
+	ac := dummyAccessCounter
+	return &FlatBtree[K, V]{
+		order:         order,
+		root:          newFlatLeafNode[K, V](ac),
+		accessCounter: ac,
+	}
+}
+
+// SetAccessCounter must be called right after NewFlat.
+func (b *FlatBtree[K, V]) SetAccessCounter(ac accessCounter) {
+	b.accessCounter = ac
+	b.root.accessCounter = ac
+}
+
+func (b *FlatBtree[K, V]) SetRebalanceCounter(rc rebalanceCounter) {
+	b.rebalanceCounter = rc
+}
+
+func (b *FlatBtree[K, V]) Find(key K) (V, bool) {
+	return b.findLeafNodeByKey(key).getValue(key)
+}
+
+// Insert replaces the value if the key is already present.
+func (b *FlatBtree[K, V]) Insert(key K, value V) {
+	leafNode := b.findLeafNodeByKey(key)
+	leafNode.insertSorted(key, value)
+	if !leafNode.isOverflow(b.order) {
+		return
+	}
+	left, right, median := leafNode.splitLeafAroundMedian()
+	if newRoot := b.replaceNodeWithTwoNodesAndSeparatorRec(leafNode, left, right, median); newRoot != nil {
+		b.root = newRoot
+	}
+}
+
+// findLeafNodeByKey is a loop instead of recursion, so the descent has no calls other than access counting.
+func (b *FlatBtree[K, V]) findLeafNodeByKey(seekedKey K) *flatNode[K, V] {
+	n := b.root
+	for {
+		n.countAccess()
+		if n.isLeaf {
+			return n
+		}
+		foundNodeIndex := len(n.keys) // if no key found, use the last range
+		for i, separator := range n.keys {
+			if separator > seekedKey {
+				foundNodeIndex = i
+				break
+			}
+		}
+		n = n.children[foundNodeIndex]
+	}
+}
+
+// replaceNodeWithTwoNodesAndSeparatorRec works like Btree.replaceNodeWithTwoNodesAndSeparatorRec.
+func (b *FlatBtree[K, V]) replaceNodeWithTwoNodesAndSeparatorRec(childToRemove, left, right *flatNode[K, V], separator K) *flatNode[K, V] {
+	if b.rebalanceCounter != nil {
+		b.rebalanceCounter()
+	}
+	childToRemove.countAccess()
+	parent := childToRemove.parent
+	if parent == nil {
+		newParent := &flatNode[K, V]{
+			children:      []*flatNode[K, V]{left, right},
+			keys:          []K{separator},
+			accessCounter: b.accessCounter,
+		}
+		left.setParent(newParent)
+		right.setParent(newParent)
+		return newParent
+	}
+	if assertionsEnabled {
+		assert(!parent.isOverflow(b.order), "parent must not be overflow at this point")
+	}
+	parent.expandAtChild(childToRemove, left, right, separator)
+	left.setParent(parent)
+	right.setParent(parent)
+	if !parent.isOverflow(b.order) {
+		return nil
+	}
+	newLeft, newRight, newMedian := parent.splitInnerAroundMedian()
+	return b.replaceNodeWithTwoNodesAndSeparatorRec(parent, newLeft, newRight, newMedian)
+}
+
+func (b *FlatBtree[K, V]) Print(w io.Writer) {
+	b.root.print(w, 0)
+}
+
+func (b *FlatBtree[K, V]) IntegrityCheck() error {
+	if b.root.parent != nil {
+		return fmt.Errorf("expected root to have no parent")
+	}
+	leafDepth := -1
+	return b.integrityCheckRec(b.root, 0, nil, nil, &leafDepth)
+}
+
+// integrityCheckRec checks that all the keys in the sub-tree are within [lo, hi) range. nil bound means no bound.
+func (b *FlatBtree[K, V]) integrityCheckRec(n *flatNode[K, V], level int, lo, hi *K, leafDepth *int) error {
+	inBounds := func(key K) bool {
+		return (lo == nil || key >= *lo) && (hi == nil || key < *hi)
+	}
+	if n.isLeaf {
+		if len(n.pairs) > b.order {
+			return fmt.Errorf("size of the leaf node is larger than the order")
+		}
+		if len(n.keys) != 0 || len(n.children) != 0 {
+			return fmt.Errorf("leaf node has keys or children")
+		}
+		if !pairSlice[K, V](n.pairs).isSorted() {
+			return fmt.Errorf("leaf pairs are not sorted")
+		}
+		for _, p := range n.pairs {
+			if !inBounds(p.key) {
+				return fmt.Errorf("leaf key %v outside of separator bounds", p.key)
+			}
+		}
+		if *leafDepth == -1 {
+			*leafDepth = level
+		}
+		if *leafDepth != level {
+			return fmt.Errorf("leaf node level differs, was %d, is %d", *leafDepth, level)
+		}
+		return nil
+	}
+	if len(n.children) != len(n.keys)+1 {
+		return fmt.Errorf("len children (%d) != len keys + 1 (%d)", len(n.children), len(n.keys))
+	}
+	if len(n.pairs) != 0 {
+		return fmt.Errorf("inner node has pairs")
+	}
+	if !slices.IsSorted(n.keys) {
+		return fmt.Errorf("keys are not sorted: %v", n.keys)
+	}
+	for _, key := range n.keys {
+		if !inBounds(key) {
+			return fmt.Errorf("separator %v outside of parent bounds", key)
+		}
+	}
+	for i, child := range n.children {
+		if child.parent != n {
+			return fmt.Errorf("parent of child node does not point to correct parent")
+		}
+		childLo, childHi := lo, hi
+		if i > 0 {
+			childLo = &n.keys[i-1]
+		}
+		if i < len(n.keys) {
+			childHi = &n.keys[i]
+		}
+		if err := b.integrityCheckRec(child, level+1, childLo, childHi, leafDepth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////
+// flatNode functions and methods
+////////////////////////////////////////
+
+func newFlatLeafNode[K cmp.Ordered, V any](ac accessCounter) *flatNode[K, V] {
+	return &flatNode[K, V]{
+		isLeaf:        true,
+		pairs:         []pair[K, V]{},
+		accessCounter: ac,
+	}
+}
+
+func (n *flatNode[K, V]) isOverflow(order int) bool {
+	n.countAccess()
+	if n.isLeaf {
+		return len(n.pairs) > order
+	}
+	return len(n.children) > order
+}
+
+func (n *flatNode[K, V]) setParent(p *flatNode[K, V]) {
+	n.countAccess()
+	n.parent = p
+}
+
+func (n *flatNode[K, V]) countAccess() {
+	n.accessCounter(n)
+}
+
+func (n *flatNode[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	if n.isLeaf {
+		for _, p := range n.pairs {
+			fmt.Fprintf(w, "%s[%v]:%v\n", spaces, p.key, p.value)
+		}
+		return
+	}
+	fmt.Fprintf(w, "%s--\n", spaces)
+	for i, key := range n.keys {
+		n.children[i].print(w, indent+1)
+		fmt.Fprintf(w, "%s%v:\n", spaces, key)
+	}
+	n.children[len(n.children)-1].print(w, indent+1)
+	fmt.Fprintf(w, "%s--\n", spaces)
+}
+
+// Inner node methods.
+
+func (n *flatNode[K, V]) expandAtChild(childToRemove, left, right *flatNode[K, V], separator K) {
+	n.countAccess()
+	i := slices.Index(n.children, childToRemove)
+	if i == -1 {
+		panic("BUG! Could not find child!")
+	}
+	n.children = slices.Delete(n.children, i, i+1)
+	n.children = slices.Insert(n.children, i, left, right)
+	n.keys = slices.Insert(n.keys, i, separator)
+}
+
+func (n *flatNode[K, V]) splitInnerAroundMedian() (*flatNode[K, V], *flatNode[K, V], K) {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(!n.isLeaf, "expected inner node")
+	}
+	iMedian := len(n.keys) / 2
+	medianValue := n.keys[iMedian]
+	newLeft := &flatNode[K, V]{
+		children:      slices.Clone(n.children[:iMedian+1]), // clone to allow GC collecting n.children
+		keys:          slices.Clone(n.keys[:iMedian]),
+		accessCounter: n.accessCounter,
+	}
+	for _, c := range newLeft.children {
+		c.setParent(newLeft)
+	}
+	newRight := &flatNode[K, V]{
+		children:      slices.Clone(n.children[iMedian+1:]),
+		keys:          slices.Clone(n.keys[iMedian+1:]),
+		accessCounter: n.accessCounter,
+	}
+	for _, c := range newRight.children {
+		c.setParent(newRight)
+	}
+	return newLeft, newRight, medianValue
+}
+
+// Leaf node methods.
+
+func (n *flatNode[K, V]) getValue(key K) (V, bool) {
+	n.countAccess()
+	pairs := pairSlice[K, V](n.pairs)
+	if i := pairs.bisect(key); i == -1 || n.pairs[i].key != key {
+		var zero V
+		return zero, false
+	} else {
+		return n.pairs[i].value, true
+	}
+}
+
+func (n *flatNode[K, V]) insertSorted(key K, value V) {
+	n.countAccess()
+	i := pairSlice[K, V](n.pairs).bisect(key)
+	if i != -1 && n.pairs[i].key == key {
+		n.pairs[i].value = value
+		return
+	}
+	newPair := pair[K, V]{key: key, value: value}
+	if i == -1 {
+		n.pairs = append(n.pairs, newPair)
+	} else {
+		n.pairs = slices.Insert(n.pairs, i, newPair)
+	}
+}
+
+func (n *flatNode[K, V]) splitLeafAroundMedian() (*flatNode[K, V], *flatNode[K, V], K) {
+	n.countAccess()
+	if assertionsEnabled {
+		assert(n.isLeaf, "expected leaf node")
+	}
+	median := n.pairs[len(n.pairs)/2].key
+	left, right := newFlatLeafNode[K, V](n.accessCounter), newFlatLeafNode[K, V](n.accessCounter)
+	for _, p := range n.pairs {
+		if p.key < median {
+			left.pairs = append(left.pairs, p)
+		} else {
+			right.pairs = append(right.pairs, p)
+		}
+	}
+	return left, right, median
+}
@@ -0,0 +1,15 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/btree/btreetest"
+	"testing"
+)
+
+func TestFlatConformance(t *testing.T) {
+	btreetest.RunConformance(t, func(order int) btreetest.Tree { return btree.NewFlat[int, int](order) })
+}
+
+func TestFlatModel(t *testing.T) {
+	btreetest.RunModel(t, func(order int) btreetest.Tree { return btree.NewFlat[int, int](order) }, "btree.NewFlat[int, int](%d)")
+}
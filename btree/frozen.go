@@ -0,0 +1,310 @@
+package btree
+
+import (
+	"cmp"
+	"fmt"
+	"math/bits"
+)
+
+// FrozenLayout is how Btree.Freeze packs the search index of a FrozenTree into an array.
+type FrozenLayout int
+
+const (
+	// FrozenLayoutBFS is a static B-tree with nodes of order-1 keys, stored level by level.
+	FrozenLayoutBFS FrozenLayout = iota
+	// FrozenLayoutVEB is a binary search tree stored in van Emde Boas order. The tree is recursively cut at half of its
+	// height, and the top sub-tree is stored before all the bottom sub-trees. It is cache oblivious, i.e. any sub-tree
+	// fitting in a cache line is in a single cache line, whatever the cache line size.
+	FrozenLayoutVEB
+	// FrozenLayoutEytzinger is a binary search tree stored level by level, where children of the node i are 2i and 2i+1.
+	FrozenLayoutEytzinger
+)
+
+func (l FrozenLayout) String() string {
+	switch l {
+	case FrozenLayoutBFS:
+		return "bfs"
+	case FrozenLayoutVEB:
+		return "veb"
+	case FrozenLayoutEytzinger:
+		return "eytzinger"
+	}
+	return "unknown"
+}
+
+func ParseFrozenLayout(s string) (FrozenLayout, error) {
+	for _, l := range []FrozenLayout{FrozenLayoutBFS, FrozenLayoutVEB, FrozenLayoutEytzinger} {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown frozen layout %q", s)
+}
+
+// FrozenTree is an immutable, read-optimised copy of a Btree. The search index is a single array in one of the
+// FrozenLayout layouts. The index finds the rank of a key, i.e. its position in keys and values sorted by key.
+type FrozenTree[K cmp.Ordered, V any] struct {
+	layout FrozenLayout
+	// keysPerNode is the number of keys in a node of FrozenLayoutBFS.
+	keysPerNode int
+	// nodes is the search index for FrozenLayoutBFS and FrozenLayoutEytzinger.
+	nodes []frozenNode[K]
+	// linkedNodes is the search index for FrozenLayoutVEB, where children positions cannot be computed as cheaply as
+	// in the other layouts.
+	linkedNodes   []frozenLinkedNode[K]
+	keys          []K
+	values        []V
+	accessCounter accessCounter
+}
+
+type frozenNode[K cmp.Ordered] struct {
+	key K
+	// rank is the index of the key in FrozenTree.keys, or -1 for padding, which is larger than any key.
+	rank int32
+}
+
+type frozenLinkedNode[K cmp.Ordered] struct {
+	key  K
+	rank int32
+	// left and right are positions of the children in FrozenTree.linkedNodes, or -1 if there is no child.
+	left  int32
+	right int32
+}
+
+// Freeze copies the tree into an immutable FrozenTree with the given layout. The FrozenTree inherits the access
+// counter of the tree.
+func (b *Btree[K, V]) Freeze(layout FrozenLayout) *FrozenTree[K, V] {
+	f := &FrozenTree[K, V]{
+		layout:        layout,
+		keysPerNode:   max(b.order-1, 1),
+		accessCounter: b.accessCounter,
+	}
+	b.root.runRecursiveUntilError(0, func(level int, n node[K, V]) error {
+		if leaf, ok := n.(leafNode[K, V]); ok {
+			for i := range leaf.len() {
+				f.keys = append(f.keys, leaf.keyAt(i))
+				f.values = append(f.values, leaf.valueAt(i))
+			}
+		}
+		return nil
+	})
+	switch layout {
+	case FrozenLayoutBFS:
+		f.buildBFS()
+	case FrozenLayoutVEB:
+		f.buildVEB()
+	case FrozenLayoutEytzinger:
+		f.buildEytzinger()
+	default:
+		panic(fmt.Sprintf("unknown frozen layout %d", layout))
+	}
+	return f
+}
+
+func (f *FrozenTree[K, V]) SetAccessCounter(ac accessCounter) {
+	f.accessCounter = ac
+}
+
+func (f *FrozenTree[K, V]) Layout() FrozenLayout {
+	return f.layout
+}
+
+func (f *FrozenTree[K, V]) Len() int {
+	return len(f.keys)
+}
+
+func (f *FrozenTree[K, V]) Find(key K) (V, bool) {
+	if i := f.lowerBound(key); i != -1 && f.keys[i] == key {
+		f.accessCounter(&f.values[i])
+		return f.values[i], true
+	}
+	var zero V
+	return zero, false
+}
+
+// Scan calls fun for the keys in [lo, hi) range in ascending order, until fun returns false.
+func (f *FrozenTree[K, V]) Scan(lo, hi K, fun func(key K, value V) bool) {
+	i := f.lowerBound(lo)
+	if i == -1 {
+		return
+	}
+	for ; i < len(f.keys) && f.keys[i] < hi; i++ {
+		f.accessCounter(&f.values[i])
+		if !fun(f.keys[i], f.values[i]) {
+			return
+		}
+	}
+}
+
+// lowerBound returns the rank of the first key not smaller than the key, or -1 if there is none.
+func (f *FrozenTree[K, V]) lowerBound(key K) int {
+	switch f.layout {
+	case FrozenLayoutBFS:
+		return f.lowerBoundBFS(key)
+	case FrozenLayoutVEB:
+		return f.lowerBoundVEB(key)
+	default:
+		return f.lowerBoundEytzinger(key)
+	}
+}
+
+////////////////////////////////////////
+// BFS layout
+////////////////////////////////////////
+
+// bfsChild returns the node index of the i-th child of the node k.
+func (f *FrozenTree[K, V]) bfsChild(k, i int) int {
+	return k*(f.keysPerNode+1) + i + 1
+}
+
+func (f *FrozenTree[K, V]) buildBFS() {
+	nNodes := (len(f.keys) + f.keysPerNode - 1) / f.keysPerNode
+	f.nodes = make([]frozenNode[K], nNodes*f.keysPerNode)
+	rank := 0
+	var build func(k int)
+	// build assigns the keys in order of in-order traversal, so the nodes form a search tree. The nodes after the last
+	// key are padding.
+	build = func(k int) {
+		if k >= nNodes {
+			return
+		}
+		for i := range f.keysPerNode {
+			build(f.bfsChild(k, i))
+			n := &f.nodes[k*f.keysPerNode+i]
+			if rank < len(f.keys) {
+				n.key, n.rank = f.keys[rank], int32(rank)
+				rank++
+			} else {
+				n.rank = -1
+			}
+		}
+		build(f.bfsChild(k, f.keysPerNode))
+	}
+	build(0)
+}
+
+func (f *FrozenTree[K, V]) lowerBoundBFS(key K) int {
+	found := -1
+	nNodes := len(f.nodes) / f.keysPerNode
+	for k := 0; k < nNodes; {
+		node := f.nodes[k*f.keysPerNode : (k+1)*f.keysPerNode]
+		f.accessCounter(&node[0])
+		i := 0
+		for i < len(node) && node[i].rank != -1 && node[i].key < key {
+			i++
+		}
+		if i < len(node) && node[i].rank != -1 {
+			found = int(node[i].rank)
+		}
+		k = f.bfsChild(k, i)
+	}
+	return found
+}
+
+////////////////////////////////////////
+// Eytzinger layout
+////////////////////////////////////////
+
+func (f *FrozenTree[K, V]) buildEytzinger() {
+	// The node 0 is unused, so that children of the node i are 2i and 2i+1.
+	f.nodes = make([]frozenNode[K], len(f.keys)+1)
+	f.nodes[0].rank = -1
+	rank := 0
+	var build func(i int)
+	build = func(i int) {
+		if i >= len(f.nodes) {
+			return
+		}
+		build(2 * i)
+		f.nodes[i] = frozenNode[K]{key: f.keys[rank], rank: int32(rank)}
+		rank++
+		build(2*i + 1)
+	}
+	build(1)
+}
+
+func (f *FrozenTree[K, V]) lowerBoundEytzinger(key K) int {
+	found := -1
+	for i := 1; i < len(f.nodes); {
+		n := &f.nodes[i]
+		f.accessCounter(n)
+		if n.key >= key {
+			found = int(n.rank)
+			i = 2 * i
+		} else {
+			i = 2*i + 1
+		}
+	}
+	return found
+}
+
+////////////////////////////////////////
+// van Emde Boas layout
+////////////////////////////////////////
+
+// buildVEB builds the same binary tree as buildEytzinger, and then stores it in van Emde Boas order.
+func (f *FrozenTree[K, V]) buildVEB() {
+	f.buildEytzinger()
+	eytzinger := f.nodes
+	f.nodes = nil
+	n := len(eytzinger) - 1
+	// positions[i] is the position in the van Emde Boas order of the node i in the Eytzinger order.
+	positions := make([]int32, len(eytzinger))
+	order := make([]int, 0, n)
+	vebOrder(1, bits.Len(uint(n)), n, &order)
+	for pos, i := range order {
+		positions[i] = int32(pos)
+	}
+	position := func(i int) int32 {
+		if i > n {
+			return -1
+		}
+		return positions[i]
+	}
+	f.linkedNodes = make([]frozenLinkedNode[K], n)
+	for i := 1; i <= n; i++ {
+		f.linkedNodes[positions[i]] = frozenLinkedNode[K]{
+			key:   eytzinger[i].key,
+			rank:  eytzinger[i].rank,
+			left:  position(2 * i),
+			right: position(2*i + 1),
+		}
+	}
+}
+
+// vebOrder appends to the order the nodes of the sub-tree of given height rooted at the node i, where the nodes are
+// numbered like in the Eytzinger layout and the nodes larger than n do not exist.
+func vebOrder(i, height, n int, order *[]int) {
+	if i > n {
+		return
+	}
+	if height == 1 {
+		*order = append(*order, i)
+		return
+	}
+	topHeight := height / 2
+	vebOrder(i, topHeight, n, order)
+	// The roots of the bottom sub-trees are the descendants of the node i, topHeight levels below.
+	firstBottom := i << topHeight
+	for j := range 1 << topHeight {
+		vebOrder(firstBottom+j, height-topHeight, n, order)
+	}
+}
+
+func (f *FrozenTree[K, V]) lowerBoundVEB(key K) int {
+	found := -1
+	if len(f.linkedNodes) == 0 {
+		return found
+	}
+	for p := int32(0); p != -1; {
+		n := &f.linkedNodes[p]
+		f.accessCounter(n)
+		if n.key >= key {
+			found = int(n.rank)
+			p = n.left
+		} else {
+			p = n.right
+		}
+	}
+	return found
+}
@@ -0,0 +1,64 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeze(t *testing.T) {
+	layouts := []btree.FrozenLayout{btree.FrozenLayoutBFS, btree.FrozenLayoutVEB, btree.FrozenLayoutEytzinger}
+	for _, layout := range layouts {
+		for _, order := range []int{2, 3, 5, 10} {
+			for _, n := range []int{0, 1, 2, 7, 100, 1000} {
+				t.Run(fmt.Sprintf("%s order %d n %d", layout, order, n), func(t *testing.T) {
+					r := rand.New(rand.NewSource(0))
+					b := btree.New[int, int](order)
+					// Even keys only, to have missing keys between the present ones.
+					for _, i := range r.Perm(n) {
+						b.Insert(i*2, i*2+1)
+					}
+					f := b.Freeze(layout)
+					assert.Equal(t, n, f.Len())
+					for i := range n {
+						v, ok := f.Find(i * 2)
+						assert.True(t, ok, "value not found for key %d", i*2)
+						assert.Equal(t, i*2+1, v)
+						_, ok = f.Find(i*2 + 1)
+						assert.False(t, ok, "value found for missing key %d", i*2+1)
+					}
+					_, ok := f.Find(-1)
+					assert.False(t, ok)
+
+					scanned := []int{}
+					f.Scan(n/2+1, n+1, func(key, value int) bool {
+						scanned = append(scanned, key)
+						return true
+					})
+					expected := []int{}
+					for k := (n/2 + 2) / 2 * 2; k < min(n+1, n*2); k += 2 {
+						expected = append(expected, k)
+					}
+					assert.Equal(t, expected, scanned)
+				})
+			}
+		}
+	}
+}
+
+func TestFrozenScanStops(t *testing.T) {
+	b := btree.New[int, int](3)
+	for i := range 100 {
+		b.Insert(i, i)
+	}
+	f := b.Freeze(btree.FrozenLayoutVEB)
+	scanned := []int{}
+	f.Scan(10, 100, func(key, value int) bool {
+		scanned = append(scanned, key)
+		return len(scanned) < 3
+	})
+	assert.Equal(t, []int{10, 11, 12}, scanned)
+}
@@ -0,0 +1,10 @@
+package btree
+
+// The InlineN types (Inline4, Inline8, ...) are B-trees of a fixed order N, where innerNode and leafNode slices are
+// replaced with arrays embedded in the node, plus a length field. Going from a node to its keys does not dereference a
+// separate backing array, and nodes never reallocate as they grow. The code is generated from
+// internal/geninline/inline.go.tmpl, since Go generics cannot parametrize array sizes.
+//
+// Orders lower than 3 are not supported, since splitting an inner node of order 2 leaves the new right node with no keys.
+
+//go:generate go run ./internal/geninline -orders 4,8,16,32,64
@@ -0,0 +1,336 @@
+// Code generated by geninline; DO NOT EDIT.
+
+package btree
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Inline8 is a B-tree of order 8 whose nodes hold keys, children and pairs in arrays embedded in the node struct.
+type Inline8[K cmp.Ordered, V any] struct {
+	// either inline8Inner or inline8Leaf
+	root             inline8Node[K, V]
+	accessCounter    accessCounter
+	rebalanceCounter rebalanceCounter
+}
+
+type inline8Node[K cmp.Ordered, V any] interface {
+	findLeafNodeByKey(key K) *inline8Leaf[K, V]
+	getParent() *inline8Inner[K, V]
+	setParent(parent *inline8Inner[K, V])
+	print(w io.Writer, indent int)
+	countAccess()
+}
+
+////////////////////////////////////////
+// Inline8 functions and methods
+////////////////////////////////////////
+
+func NewInline8[K cmp.Ordered, V any]() *Inline8[K, V] {
+	ac := dummyAccessCounter
+	return &Inline8[K, V]{
+		root:          &inline8Leaf[K, V]{accessCounter: ac},
+		accessCounter: ac,
+	}
+}
+
+// SetAccessCounter must be called right after NewInline8.
+func (b *Inline8[K, V]) SetAccessCounter(ac accessCounter) {
+	b.accessCounter = ac
+	b.root.(*inline8Leaf[K, V]).accessCounter = ac
+}
+
+func (b *Inline8[K, V]) SetRebalanceCounter(rc rebalanceCounter) {
+	b.rebalanceCounter = rc
+}
+
+func (b *Inline8[K, V]) Find(key K) (V, bool) {
+	return b.root.findLeafNodeByKey(key).getValue(key)
+}
+
+// Insert replaces the value if the key is already present.
+func (b *Inline8[K, V]) Insert(key K, value V) {
+	leaf := b.root.findLeafNodeByKey(key)
+	leaf.insertSorted(key, value)
+	if leaf.n <= 8 {
+		return
+	}
+	right, median := leaf.splitAroundMedian()
+	if newRoot := b.insertRightOfChildRec(leaf, right, median); newRoot != nil {
+		b.root = newRoot
+	}
+}
+
+// insertRightOfChildRec puts the new right node next to its left sibling, that was just split. Optionally, returns
+// new root node.
+func (b *Inline8[K, V]) insertRightOfChildRec(left, right inline8Node[K, V], separator K) *inline8Inner[K, V] {
+	if b.rebalanceCounter != nil {
+		b.rebalanceCounter()
+	}
+	parent := left.getParent()
+	if parent == nil {
+		newParent := &inline8Inner[K, V]{
+			nKeys:         1,
+			accessCounter: b.accessCounter,
+		}
+		newParent.keys[0] = separator
+		newParent.children[0] = left
+		newParent.children[1] = right
+		left.setParent(newParent)
+		right.setParent(newParent)
+		return newParent
+	}
+	parent.insertRightOfChild(left, right, separator)
+	right.setParent(parent)
+	if parent.nKeys < 8 {
+		return nil
+	}
+	newRight, newMedian := parent.splitAroundMedian()
+	return b.insertRightOfChildRec(parent, newRight, newMedian)
+}
+
+func (b *Inline8[K, V]) Print(w io.Writer) {
+	b.root.print(w, 0)
+}
+
+func (b *Inline8[K, V]) IntegrityCheck() error {
+	if b.root.getParent() != nil {
+		return fmt.Errorf("expected root to have no parent")
+	}
+	leafDepth := -1
+	return b.integrityCheckRec(b.root, 0, nil, nil, &leafDepth)
+}
+
+// integrityCheckRec checks that all the keys in the sub-tree are within [lo, hi) range. nil bound means no bound.
+func (b *Inline8[K, V]) integrityCheckRec(n inline8Node[K, V], level int, lo, hi *K, leafDepth *int) error {
+	inBounds := func(key K) bool {
+		return (lo == nil || key >= *lo) && (hi == nil || key < *hi)
+	}
+	switch t := n.(type) {
+	case *inline8Leaf[K, V]:
+		if t.n > 8 {
+			return fmt.Errorf("size of the leaf node is larger than the order")
+		}
+		for i := range t.n {
+			if i > 0 && t.pairs[i].key < t.pairs[i-1].key {
+				return fmt.Errorf("leaf pairs are not sorted")
+			}
+			if !inBounds(t.pairs[i].key) {
+				return fmt.Errorf("leaf key %v outside of separator bounds", t.pairs[i].key)
+			}
+		}
+		if *leafDepth == -1 {
+			*leafDepth = level
+		}
+		if *leafDepth != level {
+			return fmt.Errorf("leaf node level differs, was %d, is %d", *leafDepth, level)
+		}
+	case *inline8Inner[K, V]:
+		if t.nKeys < 1 || t.nKeys >= 8 {
+			return fmt.Errorf("bad number of keys in inner node: %d", t.nKeys)
+		}
+		for i := range t.nKeys {
+			if i > 0 && t.keys[i] < t.keys[i-1] {
+				return fmt.Errorf("keys are not sorted: %v", t.keys[:t.nKeys])
+			}
+			if !inBounds(t.keys[i]) {
+				return fmt.Errorf("separator %v outside of parent bounds", t.keys[i])
+			}
+		}
+		for i, child := range t.children[:t.nKeys+1] {
+			if child.getParent() != t {
+				return fmt.Errorf("parent of child node does not point to correct parent")
+			}
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = &t.keys[i-1]
+			}
+			if i < t.nKeys {
+				childHi = &t.keys[i]
+			}
+			if err := b.integrityCheckRec(child, level+1, childLo, childHi, leafDepth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////
+// Inline8 inner node functions and methods
+////////////////////////////////////////
+
+type inline8Inner[K cmp.Ordered, V any] struct {
+	// keys separate children, like in innerNode. There is room for one extra key and child, so the node can
+	// overflow before it is split.
+	keys     [8]K
+	children [9]inline8Node[K, V]
+	// nKeys is the number of used keys. The number of used children is always nKeys+1.
+	nKeys         int
+	parent        *inline8Inner[K, V]
+	accessCounter accessCounter
+}
+
+func (n *inline8Inner[K, V]) findLeafNodeByKey(seekedKey K) *inline8Leaf[K, V] {
+	n.countAccess()
+	foundNodeIndex := n.nKeys // if no key found, use the last range
+	for i, separator := range n.keys[:n.nKeys] {
+		if separator > seekedKey {
+			foundNodeIndex = i
+			break
+		}
+	}
+	return n.children[foundNodeIndex].findLeafNodeByKey(seekedKey)
+}
+
+func (n *inline8Inner[K, V]) insertRightOfChild(left, right inline8Node[K, V], separator K) {
+	n.countAccess()
+	i := 0
+	for n.children[i] != left {
+		i++
+		if i > n.nKeys {
+			panic("BUG! Could not find child!")
+		}
+	}
+	copy(n.keys[i+1:n.nKeys+1], n.keys[i:n.nKeys])
+	copy(n.children[i+2:n.nKeys+2], n.children[i+1:n.nKeys+1])
+	n.keys[i] = separator
+	n.children[i+1] = right
+	n.nKeys++
+}
+
+// splitAroundMedian keeps the left half in place and moves the right half to a new node.
+func (n *inline8Inner[K, V]) splitAroundMedian() (*inline8Inner[K, V], K) {
+	n.countAccess()
+	iMedian := n.nKeys / 2
+	medianValue := n.keys[iMedian]
+	right := &inline8Inner[K, V]{
+		nKeys:         n.nKeys - iMedian - 1,
+		accessCounter: n.accessCounter,
+	}
+	copy(right.keys[:], n.keys[iMedian+1:n.nKeys])
+	copy(right.children[:], n.children[iMedian+1:n.nKeys+1])
+	for _, c := range right.children[:right.nKeys+1] {
+		c.setParent(right)
+	}
+	var zeroKey K
+	for i := iMedian; i < n.nKeys; i++ {
+		n.keys[i] = zeroKey
+		n.children[i+1] = nil // allow GC collecting moved children
+	}
+	n.nKeys = iMedian
+	return right, medianValue
+}
+
+func (n *inline8Inner[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	fmt.Fprintf(w, "%s--\n", spaces)
+	for i, key := range n.keys[:n.nKeys] {
+		n.children[i].print(w, indent+1)
+		fmt.Fprintf(w, "%s%v:\n", spaces, key)
+	}
+	n.children[n.nKeys].print(w, indent+1)
+	fmt.Fprintf(w, "%s--\n", spaces)
+}
+
+func (n *inline8Inner[K, V]) getParent() *inline8Inner[K, V] {
+	n.countAccess()
+	return n.parent
+}
+
+func (n *inline8Inner[K, V]) setParent(p *inline8Inner[K, V]) {
+	n.countAccess()
+	n.parent = p
+}
+
+func (n *inline8Inner[K, V]) countAccess() {
+	n.accessCounter(n)
+}
+
+////////////////////////////////////////
+// Inline8 leaf node functions and methods
+////////////////////////////////////////
+
+type inline8Leaf[K cmp.Ordered, V any] struct {
+	// pairs has room for one extra pair, so the leaf can overflow before it is split.
+	pairs         [9]pair[K, V]
+	n             int
+	parent        *inline8Inner[K, V]
+	accessCounter accessCounter
+}
+
+func (n *inline8Leaf[K, V]) findLeafNodeByKey(seekedKey K) *inline8Leaf[K, V] {
+	n.countAccess()
+	return n
+}
+
+// bisect returns index of the key equal to seeked key or the first larger than seeked key, n if there is none.
+func (n *inline8Leaf[K, V]) bisect(key K) int {
+	return sort.Search(n.n, func(i int) bool {
+		return n.pairs[i].key >= key
+	})
+}
+
+func (n *inline8Leaf[K, V]) getValue(key K) (V, bool) {
+	n.countAccess()
+	if i := n.bisect(key); i == n.n || n.pairs[i].key != key {
+		var zero V
+		return zero, false
+	} else {
+		return n.pairs[i].value, true
+	}
+}
+
+func (n *inline8Leaf[K, V]) insertSorted(key K, value V) {
+	n.countAccess()
+	i := n.bisect(key)
+	if i < n.n && n.pairs[i].key == key {
+		n.pairs[i].value = value
+		return
+	}
+	copy(n.pairs[i+1:n.n+1], n.pairs[i:n.n])
+	n.pairs[i] = pair[K, V]{key: key, value: value}
+	n.n++
+}
+
+// splitAroundMedian keeps the pairs smaller than the median in place and moves the rest to a new leaf.
+func (n *inline8Leaf[K, V]) splitAroundMedian() (*inline8Leaf[K, V], K) {
+	n.countAccess()
+	median := n.pairs[n.n/2].key
+	iMedian := n.bisect(median)
+	right := &inline8Leaf[K, V]{
+		n:             n.n - iMedian,
+		accessCounter: n.accessCounter,
+	}
+	copy(right.pairs[:], n.pairs[iMedian:n.n])
+	clear(n.pairs[iMedian:n.n])
+	n.n = iMedian
+	return right, median
+}
+
+func (n *inline8Leaf[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	for _, p := range n.pairs[:n.n] {
+		fmt.Fprintf(w, "%s[%v]:%v\n", spaces, p.key, p.value)
+	}
+}
+
+func (n *inline8Leaf[K, V]) getParent() *inline8Inner[K, V] {
+	n.countAccess()
+	return n.parent
+}
+
+func (n *inline8Leaf[K, V]) setParent(p *inline8Inner[K, V]) {
+	n.countAccess()
+	n.parent = p
+}
+
+func (n *inline8Leaf[K, V]) countAccess() {
+	n.accessCounter(n)
+}
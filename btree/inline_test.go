@@ -0,0 +1,44 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/btree/btreetest"
+	"testing"
+)
+
+// inlineTree is an inline variant of a fixed order, with the Go expression of its constructor for the reproducers of
+// RunModel.
+type inlineTree struct {
+	name        string
+	order       int
+	constructor string
+	newTree     func() testedTree
+}
+
+func inlineTrees() []inlineTree {
+	return []inlineTree{
+		{"inline4", 4, "btree.NewInline4[int, int]()", func() testedTree { return btree.NewInline4[int, int]() }},
+		{"inline8", 8, "btree.NewInline8[int, int]()", func() testedTree { return btree.NewInline8[int, int]() }},
+		{"inline16", 16, "btree.NewInline16[int, int]()", func() testedTree { return btree.NewInline16[int, int]() }},
+		{"inline32", 32, "btree.NewInline32[int, int]()", func() testedTree { return btree.NewInline32[int, int]() }},
+		{"inline64", 64, "btree.NewInline64[int, int]()", func() testedTree { return btree.NewInline64[int, int]() }},
+	}
+}
+
+// TestInlineConformance runs the suite for each inline order. The orders of the suite are ignored, since the order of
+// an inline tree is fixed.
+func TestInlineConformance(t *testing.T) {
+	for _, tree := range inlineTrees() {
+		t.Run(tree.name, func(t *testing.T) {
+			btreetest.RunConformance(t, func(order int) btreetest.Tree { return tree.newTree() })
+		})
+	}
+}
+
+func TestInlineModel(t *testing.T) {
+	for _, tree := range inlineTrees() {
+		t.Run(tree.name, func(t *testing.T) {
+			btreetest.RunModel(t, func(order int) btreetest.Tree { return tree.newTree() }, tree.constructor+" // the order %d is ignored")
+		})
+	}
+}
@@ -2,163 +2,488 @@ package btree
 
 import (
 	"cmp"
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime"
 	"slices"
+	"strings"
+	"sync"
 )
 
-func (b *Btree[K, V]) IntegrityCheck() error {
-	keyPerNodeChecker := newKeyPerNodeChecker[K, V](b.root)
-	leafDepthChecker := newLeafDepthChecker[K, V]()
-	chained := chainIntegrityCheck[K, V](
-		b.integrityCheckLeafSize,
-		b.integrityCheckKeyAndChildrenLen,
-		b.integrityCheckAllButRootHaveParent,
-		b.integrityCheckParentPointsCorrectly,
-		keyPerNodeChecker.check,
-		leafDepthChecker.check,
-	)
-	return b.root.runRecursiveUntilError(0, chained)
-}
-
-func chainIntegrityCheck[K cmp.Ordered, V any](funcs ...func(level int, n node[K, V]) error) func(level int, n node[K, V]) error {
-	return func(level int, n node[K, V]) error {
-		for _, f := range funcs {
-			if err := f(level, n); err != nil {
-				return err
-			}
-		}
-		return nil
+// The kinds of the violations reported by IntegrityCheck, to be matched with errors.Is.
+var (
+	// ErrOverflow is a node with more children, or a leaf with more pairs, than the order.
+	ErrOverflow = errors.New("node is larger than the order")
+	// ErrUnderflow is a non-root node with fewer than ⌈m/2⌉ children, or a non-root leaf with fewer than half of the
+	// leaf order pairs.
+	ErrUnderflow = errors.New("node is less than half full")
+	// ErrRootChildren is an inner root with fewer than two children.
+	ErrRootChildren = errors.New("inner root has fewer than two children")
+	// ErrChildrenCount is an inner node whose number of children is not the number of keys + 1.
+	ErrChildrenCount = errors.New("len children != len keys + 1")
+	// ErrSeparatorOrder is an inner node with keys not in ascending order.
+	ErrSeparatorOrder = errors.New("keys are not sorted")
+	// ErrSeparatorBounds is a sub-tree with a key outside of the range given by the separators of its parent.
+	ErrSeparatorBounds = errors.New("key outside of the separator bounds")
+	// ErrKeyOrder is a key of a leaf smaller than the previous key, in the same leaf or in the previous leaf.
+	ErrKeyOrder = errors.New("keys of leaves are not in ascending order")
+	// ErrDuplicateKey is a key equal to the previous key, in the same leaf or in the previous leaf.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrRootParent is a root with a parent.
+	ErrRootParent = errors.New("root has a parent")
+	// ErrParent is a non-root node whose parent is not the node that has it as a child, or a node the tree owns under
+	// a node shared with a clone, see Clone.
+	ErrParent = errors.New("parent of child node does not point to correct parent")
+	// ErrLeafDepth is a leaf on a different level than the leftmost leaf, or an inner node on its level or below, which
+	// are not checked further, so the check ends on the cycles too.
+	ErrLeafDepth = errors.New("leaf node level differs")
+	// ErrSubtreeCount is an inner node whose counts of WithSubtreeCounts differ from the numbers of the pairs in the
+	// sub-trees of its children.
+	ErrSubtreeCount = errors.New("subtree count differs from the number of pairs")
+	// ErrAggregate is an inner node whose aggregates of NewWithMonoid differ from the aggregates of the values in the
+	// sub-trees of its children, compared with reflect.DeepEqual.
+	ErrAggregate = errors.New("aggregate differs from the aggregate of the values")
+)
+
+// Violation is one broken invariant of the tree. Path are the indexes of the children from the root to the node, and
+// Keys are the keys that break the invariant, if any.
+type Violation[K cmp.Ordered] struct {
+	// Err is one of the Err* kinds of violations.
+	Err   error
+	Path  []int
+	Level int
+	Keys  []K
+}
+
+func (v *Violation[K]) Error() string {
+	if len(v.Keys) == 0 {
+		return fmt.Sprintf("%v at path %v (level %d)", v.Err, v.Path, v.Level)
 	}
+	return fmt.Sprintf("%v at path %v (level %d), keys %v", v.Err, v.Path, v.Level, v.Keys)
 }
 
-func (b *Btree[K, V]) integrityCheckLeafSize(level int, n node[K, V]) error {
-	leaf, ok := n.(*leafNode[K, V])
-	if !ok {
-		return nil
+func (v *Violation[K]) Unwrap() error {
+	return v.Err
+}
+
+// IntegrityReport lists all the violations found by IntegrityCheck, in the depth-first order of the nodes.
+type IntegrityReport[K cmp.Ordered] struct {
+	Violations []*Violation[K]
+}
+
+func (r *IntegrityReport[K]) Error() string {
+	msgs := make([]string, len(r.Violations))
+	for i, v := range r.Violations {
+		msgs[i] = v.Error()
 	}
-	if len(leaf.pairs) > b.order {
-		return fmt.Errorf("size of the leaf node is larger than the order")
+	return fmt.Sprintf("%d integrity violations: %s", len(r.Violations), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the violations, so errors.Is and errors.As match any of them.
+func (r *IntegrityReport[K]) Unwrap() []error {
+	errs := make([]error, len(r.Violations))
+	for i, v := range r.Violations {
+		errs[i] = v
 	}
-	return nil
+	return errs
 }
 
-func (b *Btree[K, V]) integrityCheckKeyAndChildrenLen(level int, n node[K, V]) error {
-	inner, ok := n.(*innerNode[K, V])
-	if !ok {
+// Err returns the report as an error, or nil if there are no violations.
+func (r *IntegrityReport[K]) Err() error {
+	if len(r.Violations) == 0 {
 		return nil
 	}
-	if len(inner.children) != len(inner.keys)+1 {
-		return fmt.Errorf("len children (%d) != len keys + 1 (%d)", len(inner.children), len(inner.keys))
+	return r
+}
+
+// IntegrityCheck returns the *IntegrityReport[K] of the tree as an error, or nil if the tree is valid.
+func (b *Btree[K, V]) IntegrityCheck() error {
+	return b.IntegrityReport().Err()
+}
+
+// IntegrityReport checks all the invariants of the tree, see node, and that the keys are unique and ascending across
+// the leaves. It checks the sub-trees in parallel on GOMAXPROCS goroutines. Unlike the operations, it does not count
+// the accesses.
+func (b *Btree[K, V]) IntegrityReport() *IntegrityReport[K] {
+	return b.IntegrityReportParallel(runtime.GOMAXPROCS(0))
+}
+
+// integritySubtrees is the minimal number of the sub-trees checked in parallel. The split does not depend on the
+// number of workers, so the report is the same for any number of them.
+const integritySubtrees = 64
+
+// IntegrityReportParallel is IntegrityReport on at most workers goroutines. The nodes above the sub-trees checked in
+// parallel are checked first, by the calling goroutine. The memory used is O(height) per worker.
+func (b *Btree[K, V]) IntegrityReportParallel(workers int) *IntegrityReport[K] {
+	leafDepth := b.leftmostLeafDepth()
+	top := b.newIntegrityChecker(leafDepth)
+	top.checkRoot()
+	subtrees := top.checkTop(integritySubtree[K, V]{n: b.root, path: []int{}}, integritySubtrees)
+	checkers := make([]*integrityChecker[K, V], len(subtrees))
+	summaries := make([]subtreeSummary[V], len(subtrees))
+	runOnWorkers(len(subtrees), workers, func(i int) {
+		s := subtrees[i]
+		checkers[i] = b.newIntegrityChecker(leafDepth)
+		summaries[i] = checkers[i].checkRec(s.n, s.path, s.lo, s.hi)
+	})
+	top.checkTopSummaries(subtrees, summaries)
+
+	report := &IntegrityReport[K]{Violations: top.violations}
+	var prev *integrityChecker[K, V]
+	for _, c := range checkers {
+		report.Violations = append(report.Violations, c.violations...)
+		if !c.hasKeys {
+			continue
+		}
+		// The keys are checked to be ascending within each sub-tree, and here between the neighbouring sub-trees.
+		if prev != nil {
+			if c.firstKey == prev.lastKey {
+				report.Violations = append(report.Violations, newViolation(ErrDuplicateKey, c.firstLeafPath, c.firstKey))
+			} else if c.firstKey < prev.lastKey {
+				report.Violations = append(report.Violations, newViolation(ErrKeyOrder, c.firstLeafPath, prev.lastKey, c.firstKey))
+			}
+		}
+		prev = c
 	}
-	if !slices.IsSorted(inner.keys) {
-		return fmt.Errorf("keys are not sorted: %v", inner.keys)
+	slices.SortStableFunc(report.Violations, func(a, b *Violation[K]) int {
+		return slices.Compare(a.Path, b.Path)
+	})
+	return report
+}
+
+// localIntegrityReport checks only the nodes on the path from the root to the leaf of the key, and the parent
+// pointers of the nodes on the path.
+func (b *Btree[K, V]) localIntegrityReport(key K) *IntegrityReport[K] {
+	c := b.newIntegrityChecker(b.leftmostLeafDepth())
+	c.checkRoot()
+	path := []int{}
+	var lo, hi *K
+	n := b.root
+	for {
+		inner, ok := n.(*innerNode[K, V])
+		if !ok {
+			break
+		}
+		if len(path) >= c.leafDepth {
+			c.add(ErrLeafDepth, path)
+			return &IntegrityReport[K]{Violations: c.violations}
+		}
+		c.checkInner(inner, path, lo, hi)
+		if len(inner.children) == 0 {
+			return &IntegrityReport[K]{Violations: c.violations}
+		}
+		i := min(inner.childIndex(key), len(inner.children)-1)
+		path = append(path, i)
+		c.checkParent(inner, inner.children[i], path)
+		lo, hi = childBounds(inner, i, lo, hi)
+		n = inner.children[i]
 	}
-	return nil
+	c.checkLeaf(n.(leafNode[K, V]), path, lo, hi)
+	return &IntegrityReport[K]{Violations: c.violations}
 }
 
-func (b *Btree[K, V]) integrityCheckAllButRootHaveParent(level int, n node[K, V]) error {
-	if level == 0 {
-		if n.getParent() != nil {
-			return fmt.Errorf("expected root to have no parent")
+// leftmostLeafDepth returns the level of the leftmost leaf, or on a cycle, of the last node before the repeated one.
+func (b *Btree[K, V]) leftmostLeafDepth() int {
+	path := []node[K, V]{}
+	for n := b.root; !slices.Contains(path, n); {
+		path = append(path, n)
+		inner, ok := n.(*innerNode[K, V])
+		if !ok || len(inner.children) == 0 {
+			break
 		}
-	} else {
-		if n.getParent() == nil {
-			return fmt.Errorf("expected non-root to have parent")
+		n = inner.children[0]
+	}
+	return len(path) - 1
+}
+
+// runOnWorkers calls fun for 0 to n-1 on at most workers goroutines.
+func runOnWorkers(n, workers int, fun func(i int)) {
+	if workers <= 1 || n <= 1 {
+		for i := range n {
+			fun(i)
 		}
+		return
 	}
-	return nil
+	tasks := make(chan int)
+	wg := sync.WaitGroup{}
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				fun(i)
+			}
+		}()
+	}
+	for i := range n {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
 }
 
-func (b *Btree[K, V]) integrityCheckParentPointsCorrectly(level int, n node[K, V]) error {
-	switch t := n.(type) {
-	case *innerNode[K, V]:
-		{
-			for _, c := range t.children {
-				if c.getParent() != n {
-					return fmt.Errorf("parent of child node does not point to correct parent")
-				}
+// integritySubtree is a sub-tree to check, with all the keys expected in [lo, hi) range. nil bound means no bound.
+type integritySubtree[K cmp.Ordered, V any] struct {
+	n      node[K, V]
+	path   []int
+	lo, hi *K
+}
+
+type integrityChecker[K cmp.Ordered, V any] struct {
+	b          *Btree[K, V]
+	violations []*Violation[K]
+	// leafDepth is the level of the leftmost leaf.
+	leafDepth int
+	// firstKey is the first key checked, and firstLeafPath the path of its leaf, lastKey is the last key checked. They
+	// are set if hasKeys.
+	hasKeys       bool
+	firstKey      K
+	firstLeafPath []int
+	lastKey       K
+	// topInners are the inner nodes checked by checkTop, level by level.
+	topInners []integritySubtree[K, V]
+}
+
+func (b *Btree[K, V]) newIntegrityChecker(leafDepth int) *integrityChecker[K, V] {
+	return &integrityChecker[K, V]{b: b, leafDepth: leafDepth}
+}
+
+func newViolation[K cmp.Ordered](err error, path []int, keys ...K) *Violation[K] {
+	return &Violation[K]{
+		Err:   err,
+		Path:  slices.Clone(path),
+		Level: len(path),
+		Keys:  keys,
+	}
+}
+
+// checkRoot checks the rules only for the root, Knuth rule 3 and that it has no parent, unless it is shared with a
+// clone.
+func (c *integrityChecker[K, V]) checkRoot() {
+	if inner, ok := c.b.root.(*innerNode[K, V]); ok && len(inner.children) < 2 {
+		c.add(ErrRootChildren, nil)
+	}
+	if ownerOf(c.b.root) == c.b.owner && parentOf(c.b.root) != nil {
+		c.add(ErrRootParent, nil)
+	}
+}
+
+func (c *integrityChecker[K, V]) add(err error, path []int, keys ...K) {
+	c.violations = append(c.violations, newViolation(err, path, keys...))
+}
+
+// checkTop checks the nodes from the root down, level by level, until the level has at least n nodes or a leaf. It
+// returns the sub-trees of the last level, not checked yet.
+func (c *integrityChecker[K, V]) checkTop(root integritySubtree[K, V], n int) []integritySubtree[K, V] {
+	level := []integritySubtree[K, V]{root}
+	for depth := 0; len(level) < n && depth < c.leafDepth; depth++ {
+		for _, s := range level {
+			if _, ok := s.n.(leafNode[K, V]); ok {
+				return level
+			}
+		}
+		next := []integritySubtree[K, V]{}
+		for _, s := range level {
+			inner := s.n.(*innerNode[K, V])
+			c.checkInner(inner, s.path, s.lo, s.hi)
+			c.topInners = append(c.topInners, s)
+			for i, child := range inner.children {
+				path := append(slices.Clone(s.path), i)
+				c.checkParent(inner, child, path)
+				lo, hi := childBounds(inner, i, s.lo, s.hi)
+				next = append(next, integritySubtree[K, V]{n: child, path: path, lo: lo, hi: hi})
 			}
 		}
+		if len(next) == 0 {
+			return level
+		}
+		level = next
 	}
-	return nil
+	return level
 }
 
-type keyPerNodeChecker[K cmp.Ordered, V any] struct {
-	keysPerNode map[node[K, V]][]K
+// subtreeSummary is what the inner nodes keep about the sub-trees of their children, recomputed by the checker.
+type subtreeSummary[V any] struct {
+	size int
+	// aggregate is set if the tree has a monoid.
+	aggregate V
 }
 
-func newKeyPerNodeChecker[K cmp.Ordered, V any](n node[K, V]) *keyPerNodeChecker[K, V] {
-	c := &keyPerNodeChecker[K, V]{
-		keysPerNode: make(map[node[K, V]][]K),
+// checkTopSummaries checks the counts and the aggregates of the nodes checked by checkTop, given the summaries of the
+// sub-trees it returned.
+func (c *integrityChecker[K, V]) checkTopSummaries(subtrees []integritySubtree[K, V], subtreeSummaries []subtreeSummary[V]) {
+	summaries := make(map[node[K, V]]subtreeSummary[V], len(subtrees)+len(c.topInners))
+	for i, s := range subtrees {
+		summaries[s.n] = subtreeSummaries[i]
+	}
+	// The children are on the levels below, so checked before their parents.
+	for i := len(c.topInners) - 1; i >= 0; i-- {
+		s := c.topInners[i]
+		inner := s.n.(*innerNode[K, V])
+		summaries[inner] = c.checkSummaries(inner, s.path, func(j int) subtreeSummary[V] {
+			return summaries[inner.children[j]]
+		})
 	}
-	c.collectKeysPerNode(n)
-	return c
 }
 
-func (c *keyPerNodeChecker[K, V]) collectKeysPerNode(n node[K, V]) {
+// checkRec checks the sub-tree and returns its summary.
+func (c *integrityChecker[K, V]) checkRec(n node[K, V], path []int, lo, hi *K) subtreeSummary[V] {
 	switch t := n.(type) {
-	case *leafNode[K, V]:
-		keys := []K{}
-		for _, p := range t.pairs {
-			keys = append(keys, p.key)
+	case leafNode[K, V]:
+		c.checkLeaf(t, path, lo, hi)
+		summary := subtreeSummary[V]{size: t.len()}
+		if m := c.b.monoid; m != nil {
+			summary.aggregate = m.Identity
+			for i := range t.len() {
+				summary.aggregate = m.Combine(summary.aggregate, m.Lift(t.valueAt(i)))
+			}
 		}
-		assert(c.keysPerNode[n] == nil)
-		c.keysPerNode[n] = keys
+		return summary
 	case *innerNode[K, V]:
-		keys := []K{}
-		for _, child := range t.children {
-			c.collectKeysPerNode(child)
-			subKeys := c.keysPerNode[child]
-			assert(subKeys != nil)
-			keys = append(keys, subKeys...)
+		if len(path) >= c.leafDepth {
+			c.add(ErrLeafDepth, path)
+			return subtreeSummary[V]{}
 		}
-		assert(c.keysPerNode[n] == nil)
-		c.keysPerNode[n] = keys
+		c.checkInner(t, path, lo, hi)
+		return c.checkSummaries(t, path, func(i int) subtreeSummary[V] {
+			childPath := append(path, i)
+			c.checkParent(t, t.children[i], childPath)
+			childLo, childHi := childBounds(t, i, lo, hi)
+			return c.checkRec(t.children[i], childPath, childLo, childHi)
+		})
 	}
+	return subtreeSummary[V]{}
 }
 
-func (c *keyPerNodeChecker[K, V]) check(level int, n node[K, V]) error {
-	inner, ok := n.(*innerNode[K, V])
-	if !ok {
-		return nil
+// checkSummaries checks the counts and the aggregates of the inner node, if the tree keeps them, against the summaries
+// of the sub-trees of its children, given in order by summaryOf, and returns the summary of the node.
+func (c *integrityChecker[K, V]) checkSummaries(inner *innerNode[K, V], path []int, summaryOf func(i int) subtreeSummary[V]) subtreeSummary[V] {
+	m := c.b.monoid
+	s := inner.summaries
+	countsValid := !c.b.subtreeCounts || s != nil && len(s.counts) == len(inner.children)
+	aggregatesValid := m == nil || s != nil && len(s.aggregates) == len(inner.children)
+	summary := subtreeSummary[V]{}
+	if m != nil {
+		summary.aggregate = m.Identity
 	}
-	for i, child := range inner.children {
-		keysForChild := c.keysPerNode[child]
-		assert(keysForChild != nil)
-		leftmost := i == 0
-		rightmost := i == len(inner.keys)
-		minKey := slices.Min(keysForChild)
-		maxKey := slices.Max(keysForChild)
-		if !leftmost && !(minKey >= inner.keys[i-1]) {
-			return fmt.Errorf("bad min key")
+	for i := range inner.children {
+		child := summaryOf(i)
+		if c.b.subtreeCounts && countsValid && s.counts[i] != child.size {
+			countsValid = false
 		}
-		if !rightmost && !(maxKey < inner.keys[i]) {
-			return fmt.Errorf("mad max key")
+		summary.size += child.size
+		if m != nil {
+			if aggregatesValid && !reflect.DeepEqual(s.aggregates[i], child.aggregate) {
+				aggregatesValid = false
+			}
+			summary.aggregate = m.Combine(summary.aggregate, child.aggregate)
 		}
 	}
-	return nil
+	if !countsValid {
+		c.add(ErrSubtreeCount, path)
+	}
+	if !aggregatesValid {
+		c.add(ErrAggregate, path)
+	}
+	return summary
 }
 
-type leafDepthChecker[K cmp.Ordered, V any] struct {
-	leafNodeDepth int
+// childBounds returns the range of the keys of the i-th child. With a wrong number of keys, the children get the range
+// of the parent.
+func childBounds[K cmp.Ordered, V any](inner *innerNode[K, V], i int, lo, hi *K) (*K, *K) {
+	if len(inner.children) != len(inner.keys)+1 {
+		return lo, hi
+	}
+	if i > 0 {
+		lo = &inner.keys[i-1]
+	}
+	if i < len(inner.keys) {
+		hi = &inner.keys[i]
+	}
+	return lo, hi
 }
 
-func newLeafDepthChecker[K cmp.Ordered, V any]() *leafDepthChecker[K, V] {
-	return &leafDepthChecker[K, V]{
-		leafNodeDepth: -1,
-	}
+func inBounds[K cmp.Ordered](key K, lo, hi *K) bool {
+	return (lo == nil || key >= *lo) && (hi == nil || key < *hi)
 }
 
-func (c *leafDepthChecker[K, V]) check(level int, n node[K, V]) error {
-	_, ok := n.(*leafNode[K, V])
-	if !ok {
-		return nil
+// checkParent checks the parent pointer of the child if the tree owns it. The parent pointers of the nodes shared with a
+// clone are not kept, see Clone.
+func (c *integrityChecker[K, V]) checkParent(parent *innerNode[K, V], child node[K, V], path []int) {
+	if ownerOf(child) != c.b.owner {
+		return
 	}
-	if c.leafNodeDepth == -1 {
-		c.leafNodeDepth = level
+	if parent.owner != c.b.owner || parentOf(child) != parent {
+		c.add(ErrParent, path)
 	}
-	if c.leafNodeDepth != level {
-		return fmt.Errorf("leaf node level differs, was %d, is %d", c.leafNodeDepth, level)
+}
+
+// parentOf returns the parent of the node like getParent, but without counting the access, so the checks running on
+// several goroutines do not call the access counter.
+func parentOf[K cmp.Ordered, V any](n node[K, V]) *innerNode[K, V] {
+	switch t := n.(type) {
+	case *innerNode[K, V]:
+		return t.parent
+	case *aosLeafNode[K, V]:
+		return t.parent
+	case *soaLeafNode[K, V]:
+		return t.parent
+	case *keysLeafNode[K, V]:
+		return t.parent
 	}
 	return nil
 }
+
+func (c *integrityChecker[K, V]) checkLeaf(leaf leafNode[K, V], path []int, lo, hi *K) {
+	if leaf.len() > c.b.leafOrder {
+		c.add(ErrOverflow, path)
+	}
+	if len(path) > 0 && leaf.len() < minOccupancy(c.b.leafOrder) {
+		c.add(ErrUnderflow, path)
+	}
+	if c.leafDepth != len(path) {
+		c.add(ErrLeafDepth, path)
+	}
+	for i := range leaf.len() {
+		key := leaf.keyAt(i)
+		if !inBounds(key, lo, hi) {
+			c.add(ErrSeparatorBounds, path, key)
+		}
+		if !c.hasKeys {
+			c.hasKeys, c.firstKey, c.firstLeafPath = true, key, slices.Clone(path)
+		} else if key == c.lastKey {
+			c.add(ErrDuplicateKey, path, key)
+		} else if key < c.lastKey {
+			c.add(ErrKeyOrder, path, c.lastKey, key)
+		}
+		c.lastKey = key
+	}
+}
+
+func (c *integrityChecker[K, V]) checkInner(inner *innerNode[K, V], path []int, lo, hi *K) {
+	if len(inner.children) > c.b.order {
+		c.add(ErrOverflow, path)
+	}
+	if len(path) > 0 && len(inner.children) < minOccupancy(c.b.order) {
+		c.add(ErrUnderflow, path)
+	}
+	if len(inner.children) != len(inner.keys)+1 {
+		c.add(ErrChildrenCount, path)
+		return
+	}
+	if !slices.IsSorted(inner.keys) {
+		c.add(ErrSeparatorOrder, path, inner.keys...)
+	}
+	for _, key := range inner.keys {
+		if !inBounds(key, lo, hi) {
+			c.add(ErrSeparatorBounds, path, key)
+		}
+	}
+}
+
+// minOccupancy is ⌈m/2⌉, the minimal number of the children of a non-root inner node of order m. The leaves split
+// the same way, so it is also the minimal number of the pairs of a non-root leaf.
+func minOccupancy(order int) int {
+	return max(1, (order+1)/2)
+}
@@ -0,0 +1,55 @@
+// geninline generates the fixed-capacity B-tree variants (btree.Inline4, btree.Inline8, ...) from inline.go.tmpl.
+// Array sizes cannot be type parameters, so each order gets its own copy of the code.
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed inline.go.tmpl
+var inlineTemplate string
+
+func main() {
+	flagOrders := ""
+	flag.StringVar(&flagOrders, "orders", "4,8,16,32,64", "comma separated orders to generate the variants for")
+	flag.Parse()
+	tmpl := template.Must(template.New("inline").Parse(inlineTemplate))
+	for _, s := range strings.Split(flagOrders, ",") {
+		order, err := strconv.Atoi(s)
+		if err != nil || order < 3 {
+			fmt.Fprintf(os.Stderr, "bad order %q, must be an integer >= 3\n", s)
+			os.Exit(1)
+		}
+		if err := generate(tmpl, order); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func generate(tmpl *template.Template, order int) error {
+	var buf bytes.Buffer
+	data := struct {
+		Order        int
+		OrderPlusOne int
+	}{
+		Order:        order,
+		OrderPlusOne: order + 1,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated source for order %d: %w", order, err)
+	}
+	return os.WriteFile(fmt.Sprintf("inline%d.go", order), src, 0o644)
+}
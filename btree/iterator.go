@@ -0,0 +1,159 @@
+package btree
+
+import (
+	"cmp"
+	"container/heap"
+)
+
+// cursor walks the pairs of a tree in ascending order of the keys, leaf by leaf. The path are the inner nodes above the
+// leaf, with the index of the child on the way to it.
+type cursor[K cmp.Ordered, V any] struct {
+	path []pathStep[K, V]
+	leaf leafNode[K, V]
+	// i is the index of the current pair in the leaf.
+	i int
+}
+
+// pathStep is an inner node on the path of a cursor, and the index of the next node on the path among its children.
+type pathStep[K cmp.Ordered, V any] struct {
+	node  *innerNode[K, V]
+	index int
+}
+
+// newCursor returns a cursor before the first pair of the tree.
+func newCursor[K cmp.Ordered, V any](b *Btree[K, V]) *cursor[K, V] {
+	c := &cursor[K, V]{}
+	c.descend(b.root)
+	c.i = -1
+	return c
+}
+
+// descend moves the cursor to the leftmost leaf of the sub-tree.
+func (c *cursor[K, V]) descend(n node[K, V]) {
+	for {
+		inner, ok := n.(*innerNode[K, V])
+		if !ok {
+			break
+		}
+		inner.countAccess()
+		c.path = append(c.path, pathStep[K, V]{inner, 0})
+		n = inner.children[0]
+	}
+	c.leaf = n.(leafNode[K, V])
+	c.leaf.countAccess()
+	c.i = 0
+}
+
+// next moves the cursor to the next pair, and returns false if there is none.
+func (c *cursor[K, V]) next() bool {
+	c.i++
+	for c.i >= c.leaf.len() {
+		for len(c.path) > 0 && c.path[len(c.path)-1].index == len(c.path[len(c.path)-1].node.children)-1 {
+			c.path = c.path[:len(c.path)-1]
+		}
+		if len(c.path) == 0 {
+			// Stay after the last pair, so the next calls return false too.
+			c.i = c.leaf.len()
+			return false
+		}
+		step := &c.path[len(c.path)-1]
+		step.index++
+		c.descend(step.node.children[step.index])
+	}
+	return true
+}
+
+func (c *cursor[K, V]) key() K {
+	return c.leaf.keyAt(c.i)
+}
+
+func (c *cursor[K, V]) value() V {
+	return c.leaf.valueAt(c.i)
+}
+
+// MergeIterator walks the pairs of several trees in ascending order of the keys, like a k-way merge, in
+// O(log k) per pair. The pairs with the same key are returned one after another, in the order of the trees. The trees
+// must not be modified during the iteration.
+//
+//	it := NewMergeIterator(a, b, c)
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value(), it.Source())
+//	}
+type MergeIterator[K cmp.Ordered, V any] struct {
+	cursors mergeHeap[K, V]
+	// current is the cursor of the current pair, removed from the heap until Next.
+	current *mergeCursor[K, V]
+}
+
+type mergeCursor[K cmp.Ordered, V any] struct {
+	*cursor[K, V]
+	// source is the index of the tree.
+	source int
+}
+
+func NewMergeIterator[K cmp.Ordered, V any](trees ...*Btree[K, V]) *MergeIterator[K, V] {
+	it := &MergeIterator[K, V]{}
+	for i, b := range trees {
+		c := &mergeCursor[K, V]{newCursor(b), i}
+		if c.next() {
+			it.cursors = append(it.cursors, c)
+		}
+	}
+	heap.Init(&it.cursors)
+	return it
+}
+
+// Next moves to the next pair, and returns false if there is none. It must be called before the first pair too.
+func (it *MergeIterator[K, V]) Next() bool {
+	if it.current != nil && it.current.next() {
+		heap.Push(&it.cursors, it.current)
+	}
+	it.current = nil
+	if len(it.cursors) == 0 {
+		return false
+	}
+	it.current = heap.Pop(&it.cursors).(*mergeCursor[K, V])
+	return true
+}
+
+func (it *MergeIterator[K, V]) Key() K {
+	return it.current.key()
+}
+
+func (it *MergeIterator[K, V]) Value() V {
+	return it.current.value()
+}
+
+// Source returns the index of the tree of the current pair, among the trees given to NewMergeIterator.
+func (it *MergeIterator[K, V]) Source() int {
+	return it.current.source
+}
+
+// mergeHeap is a heap.Interface of the cursors, ordered by the current key and then by the source.
+type mergeHeap[K cmp.Ordered, V any] []*mergeCursor[K, V]
+
+func (h mergeHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap[K, V]) Less(i, j int) bool {
+	if c := cmp.Compare(h[i].key(), h[j].key()); c != 0 {
+		return c < 0
+	}
+	return h[i].source < h[j].source
+}
+
+func (h mergeHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap[K, V]) Push(x any) {
+	*h = append(*h, x.(*mergeCursor[K, V]))
+}
+
+func (h *mergeHeap[K, V]) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
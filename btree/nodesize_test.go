@@ -0,0 +1,63 @@
+package btree
+
+import (
+	"strconv"
+	"testing"
+	"unsafe"
+)
+
+// TestNodeSizes checks the sizes of the nodes, so a new field of a node is a deliberate change.
+func TestNodeSizes(t *testing.T) {
+	if strconv.IntSize != 64 {
+		t.Skip("the sizes are of the 64-bit platforms")
+	}
+	if size := unsafe.Sizeof(innerNode[int, int]{}); size != 80 {
+		t.Errorf("innerNode is %d bytes, expected 80", size)
+	}
+	if size := unsafe.Sizeof(aosLeafNode[int, int]{}); size != 48 {
+		t.Errorf("aosLeafNode is %d bytes, expected 48", size)
+	}
+	if size := unsafe.Sizeof(keysLeafNode[int, struct{}]{}); size != 48 {
+		t.Errorf("keysLeafNode is %d bytes, expected 48", size)
+	}
+	if _, ok := NewSet[int](3).tree.root.(*keysLeafNode[int, struct{}]); !ok {
+		t.Errorf("the leaves of a set are not keysLeafNode")
+	}
+}
+
+// TestSummariesInNodes checks that every inner node of a tree with the counts and the aggregates keeps both, per child,
+// also after the relayout moves the nodes.
+func TestSummariesInNodes(t *testing.T) {
+	sum := Monoid[int]{
+		Identity: 0,
+		Combine:  func(a, b int) int { return a + b },
+		Lift:     func(value int) int { return value },
+	}
+	b := NewWithMonoid[int, int](3, sum, WithSubtreeCounts())
+	for i := range 500 {
+		b.Insert(i, i)
+	}
+	check := func() {
+		var rec func(n node[int, int])
+		rec = func(n node[int, int]) {
+			inner, ok := n.(*innerNode[int, int])
+			if !ok {
+				return
+			}
+			s := inner.summaries
+			if s == nil || len(s.counts) != len(inner.children) || len(s.aggregates) != len(inner.children) {
+				t.Fatalf("expected the counts and the aggregates of %d children, got %+v", len(inner.children), s)
+			}
+			for _, c := range inner.children {
+				rec(c)
+			}
+		}
+		rec(b.root)
+	}
+	check()
+	b.Relayout(LayoutDFS)
+	check()
+	if got := b.Aggregate(0, 500); got != 124750 {
+		t.Errorf("expected sum 124750, got %d", got)
+	}
+}
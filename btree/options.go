@@ -0,0 +1,115 @@
+package btree
+
+import "fmt"
+
+// Option configures a Btree created with New.
+type Option func(*options)
+
+type options struct {
+	leafOrder  int
+	leafLayout LeafLayout
+	// leafLayoutSet is true if WithLeafLayout is used, even with the default layout.
+	leafLayoutSet          bool
+	shuffledAllocation     bool
+	shuffledAllocationSeed int64
+	paranoidLevel          ParanoidLevel
+	paranoidSampleRate     float64
+	subtreeCounts          bool
+}
+
+// LeafLayout is how leaf nodes store keys and values in memory.
+type LeafLayout int
+
+const (
+	// LeafLayoutAoS stores an array of {key, value} pairs. This is the default.
+	LeafLayoutAoS LeafLayout = iota
+	// LeafLayoutSoA stores parallel arrays of keys and values, so searching the keys does not bring the values to cache.
+	LeafLayoutSoA
+	// leafLayoutKeys stores only the keys, for Set.
+	leafLayoutKeys
+)
+
+func (l LeafLayout) String() string {
+	switch l {
+	case LeafLayoutAoS:
+		return "aos"
+	case LeafLayoutSoA:
+		return "soa"
+	case leafLayoutKeys:
+		return "keys"
+	}
+	return "unknown"
+}
+
+// WithLeafOrder sets the maximum number of pairs in a leaf node, which otherwise is the same as the order. It panics
+// if the leaf order is smaller than 1.
+func WithLeafOrder(leafOrder int) Option {
+	if leafOrder < 1 {
+		panic(fmt.Sprintf("leaf order must be at least 1, got %d", leafOrder))
+	}
+	return func(o *options) {
+		o.leafOrder = leafOrder
+	}
+}
+
+func WithLeafLayout(layout LeafLayout) Option {
+	return func(o *options) {
+		o.leafLayout = layout
+		o.leafLayoutSet = true
+	}
+}
+
+// WithShuffledAllocation preallocates the nodes and hands them out in random order, instead of allocating them on the
+// heap as they are created. The tree has the same shape, but the placement of the nodes in memory is unrelated to the
+// order of inserts, which separates the physical locality from the logical one. The trees derived from the tree, like
+// the results of the Set operations, allocate their nodes on the heap.
+func WithShuffledAllocation(seed int64) Option {
+	return func(o *options) {
+		o.shuffledAllocation = true
+		o.shuffledAllocationSeed = seed
+	}
+}
+
+// ParanoidLevel is how much of the tree WithParanoidChecks checks after a mutation.
+type ParanoidLevel int
+
+const (
+	// ParanoidOff checks nothing. This is the default.
+	ParanoidOff ParanoidLevel = iota
+	// ParanoidLocal checks the nodes on the path from the root to the mutated leaf, in O(order * height).
+	ParanoidLocal
+	// ParanoidFull runs IntegrityCheck, in O(n).
+	ParanoidFull
+)
+
+func (l ParanoidLevel) String() string {
+	switch l {
+	case ParanoidOff:
+		return "off"
+	case ParanoidLocal:
+		return "local"
+	case ParanoidFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// WithParanoidChecks checks the integrity of the tree after a random sample of the mutations, the inserts, SplitAt,
+// Join and DeleteRange, with given probability of checking a mutation, so 1 checks all of them. The
+// first violation is returned by Err instead of panicking, and stops the checks. Unlike the assertions, the checks do
+// not need a special build, but they do not count the accesses.
+func WithParanoidChecks(level ParanoidLevel, sampleRate float64) Option {
+	return func(o *options) {
+		o.paranoidLevel = level
+		o.paranoidSampleRate = sampleRate
+	}
+}
+
+// WithSubtreeCounts keeps the number of the pairs in the sub-tree of every child of the inner nodes, so Rank, Select
+// and CountRange take O(log n) instead of O(n). It costs an int per child, kept in the inner nodes behind a pointer,
+// and updating the counts on the path on every insert.
+func WithSubtreeCounts() Option {
+	return func(o *options) {
+		o.subtreeCounts = true
+	}
+}
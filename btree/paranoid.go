@@ -0,0 +1,64 @@
+package btree
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// paranoidChecks are the state of WithParanoidChecks.
+type paranoidChecks struct {
+	level      ParanoidLevel
+	sampleRate float64
+	rand       *rand.Rand
+	// err is the first violation found, after which nothing is checked.
+	err error
+}
+
+func newParanoidChecks(level ParanoidLevel, sampleRate float64) *paranoidChecks {
+	return &paranoidChecks{
+		level:      level,
+		sampleRate: sampleRate,
+		rand:       rand.New(rand.NewSource(0)),
+	}
+}
+
+// sample returns true if the current mutation should be checked.
+func (p *paranoidChecks) sample() bool {
+	return p.err == nil && (p.sampleRate >= 1 || p.rand.Float64() < p.sampleRate)
+}
+
+// paranoidCheck checks the tree after the key was inserted, if the insert is sampled.
+func (b *Btree[K, V]) paranoidCheck(key K) {
+	if !b.paranoid.sample() {
+		return
+	}
+	var report *IntegrityReport[K]
+	if b.paranoid.level == ParanoidFull {
+		report = b.IntegrityReport()
+	} else {
+		report = b.localIntegrityReport(key)
+	}
+	if err := report.Err(); err != nil {
+		b.paranoid.err = fmt.Errorf("%s check after insert of %v: %w", b.paranoid.level, key, err)
+	}
+}
+
+// Err returns the first violation found by WithParanoidChecks, or nil.
+func (b *Btree[K, V]) Err() error {
+	if b.paranoid == nil {
+		return nil
+	}
+	return b.paranoid.err
+}
+
+// paranoidCheckTree checks the whole tree after the mutation, described by the format and its arguments, if the
+// mutation is sampled. SplitAt, Join and DeleteRange rebuild the nodes along two paths, so both levels run
+// IntegrityCheck.
+func (b *Btree[K, V]) paranoidCheckTree(format string, a ...any) {
+	if !b.paranoid.sample() {
+		return
+	}
+	if err := b.IntegrityCheck(); err != nil {
+		b.paranoid.err = fmt.Errorf("%s check after %s: %w", b.paranoid.level, fmt.Sprintf(format, a...), err)
+	}
+}
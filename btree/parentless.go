@@ -0,0 +1,305 @@
+package btree
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ParentlessBtree is a B-tree with the same algorithm as Btree, but with no parent pointers in the nodes. Insert records
+// the root-to-leaf path during the descent and walks it back up when splitting, so a split does not need to touch every
+// moved child to fix its parent pointer.
+type ParentlessBtree[K cmp.Ordered, V any] struct {
+	// The maximum number of child nodes of a node.
+	order int
+	// either parentlessInnerNode or parentlessLeafNode
+	root parentlessNode[K, V]
+	// path is reused between insertions to avoid allocating it on every descent.
+	path             []*parentlessInnerNode[K, V]
+	accessCounter    accessCounter
+	rebalanceCounter rebalanceCounter
+}
+
+type parentlessNode[K cmp.Ordered, V any] interface {
+	// findLeafNodeByKey works like node.findLeafNodeByKey, and appends the inner nodes on the way to the path, if path
+	// is not nil.
+	findLeafNodeByKey(key K, path *[]*parentlessInnerNode[K, V]) *parentlessLeafNode[K, V]
+	print(w io.Writer, indent int)
+	countAccess()
+}
+
+////////////////////////////////////////
+// ParentlessBtree functions and methods
+////////////////////////////////////////
+
+func NewParentless[K cmp.Ordered, V any](order int) *ParentlessBtree[K, V] {
+	ac := dummyAccessCounter
+	return &ParentlessBtree[K, V]{
+		order:         order,
+		root:          newParentlessLeafNode[K, V](ac),
+		accessCounter: ac,
+	}
+}
+
+// SetAccessCounter must be called right after NewParentless.
+func (b *ParentlessBtree[K, V]) SetAccessCounter(ac accessCounter) {
+	b.accessCounter = ac
+	b.root.(*parentlessLeafNode[K, V]).accessCounter = ac
+}
+
+func (b *ParentlessBtree[K, V]) SetRebalanceCounter(rc rebalanceCounter) {
+	b.rebalanceCounter = rc
+}
+
+func (b *ParentlessBtree[K, V]) Find(key K) (V, bool) {
+	return b.root.findLeafNodeByKey(key, nil).getValue(key)
+}
+
+// Insert replaces the value if the key is already present.
+func (b *ParentlessBtree[K, V]) Insert(key K, value V) {
+	b.path = b.path[:0]
+	leaf := b.root.findLeafNodeByKey(key, &b.path)
+	leaf.insertSorted(key, value)
+	if !leaf.isOverflow(b.order) {
+		return
+	}
+	left, right, median := leaf.splitAroundMedian()
+	if newRoot := b.replaceNodeWithTwoNodesAndSeparatorRec(b.path, leaf, left, right, median); newRoot != nil {
+		b.root = newRoot
+	}
+}
+
+// replaceNodeWithTwoNodesAndSeparatorRec works like Btree.replaceNodeWithTwoNodesAndSeparatorRec, but the parent is the
+// last node on the path from root to childToRemove.
+func (b *ParentlessBtree[K, V]) replaceNodeWithTwoNodesAndSeparatorRec(path []*parentlessInnerNode[K, V], childToRemove, left, right parentlessNode[K, V], separator K) *parentlessInnerNode[K, V] {
+	if b.rebalanceCounter != nil {
+		b.rebalanceCounter()
+	}
+	if len(path) == 0 {
+		return &parentlessInnerNode[K, V]{
+			children:      []parentlessNode[K, V]{left, right},
+			keys:          []K{separator},
+			accessCounter: b.accessCounter,
+		}
+	}
+	parent := path[len(path)-1]
+	if assertionsEnabled {
+		assert(!parent.isOverflow(b.order), "parent must not be overflow at this point")
+	}
+	parent.expandAtChild(childToRemove, left, right, separator)
+	if !parent.isOverflow(b.order) {
+		return nil
+	}
+	newLeft, newRight, newMedian := parent.splitAroundMedian()
+	return b.replaceNodeWithTwoNodesAndSeparatorRec(path[:len(path)-1], parent, newLeft, newRight, newMedian)
+}
+
+func (b *ParentlessBtree[K, V]) Print(w io.Writer) {
+	b.root.print(w, 0)
+}
+
+func (b *ParentlessBtree[K, V]) IntegrityCheck() error {
+	leafDepth := -1
+	return b.integrityCheckRec(b.root, 0, nil, nil, &leafDepth)
+}
+
+// integrityCheckRec checks that all the keys in the sub-tree are within [lo, hi) range. nil bound means no bound.
+func (b *ParentlessBtree[K, V]) integrityCheckRec(n parentlessNode[K, V], level int, lo, hi *K, leafDepth *int) error {
+	inBounds := func(key K) bool {
+		return (lo == nil || key >= *lo) && (hi == nil || key < *hi)
+	}
+	switch t := n.(type) {
+	case *parentlessLeafNode[K, V]:
+		if len(t.pairs) > b.order {
+			return fmt.Errorf("size of the leaf node is larger than the order")
+		}
+		if !pairSlice[K, V](t.pairs).isSorted() {
+			return fmt.Errorf("leaf pairs are not sorted")
+		}
+		for _, p := range t.pairs {
+			if !inBounds(p.key) {
+				return fmt.Errorf("leaf key %v outside of separator bounds", p.key)
+			}
+		}
+		if *leafDepth == -1 {
+			*leafDepth = level
+		}
+		if *leafDepth != level {
+			return fmt.Errorf("leaf node level differs, was %d, is %d", *leafDepth, level)
+		}
+	case *parentlessInnerNode[K, V]:
+		if len(t.children) != len(t.keys)+1 {
+			return fmt.Errorf("len children (%d) != len keys + 1 (%d)", len(t.children), len(t.keys))
+		}
+		if !slices.IsSorted(t.keys) {
+			return fmt.Errorf("keys are not sorted: %v", t.keys)
+		}
+		for _, key := range t.keys {
+			if !inBounds(key) {
+				return fmt.Errorf("separator %v outside of parent bounds", key)
+			}
+		}
+		for i, child := range t.children {
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = &t.keys[i-1]
+			}
+			if i < len(t.keys) {
+				childHi = &t.keys[i]
+			}
+			if err := b.integrityCheckRec(child, level+1, childLo, childHi, leafDepth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////
+// Parentless inner node functions and methods
+////////////////////////////////////////
+
+// parentlessInnerNode is like innerNode, but without the parent pointer.
+type parentlessInnerNode[K cmp.Ordered, V any] struct {
+	children      []parentlessNode[K, V]
+	keys          []K
+	accessCounter accessCounter
+}
+
+func (n *parentlessInnerNode[K, V]) findLeafNodeByKey(seekedKey K, path *[]*parentlessInnerNode[K, V]) *parentlessLeafNode[K, V] {
+	n.countAccess()
+	foundNodeIndex := len(n.keys) // if no key found, use the last range
+	for i, separator := range n.keys {
+		if separator > seekedKey {
+			foundNodeIndex = i
+			break
+		}
+	}
+	if path != nil {
+		*path = append(*path, n)
+	}
+	return n.children[foundNodeIndex].findLeafNodeByKey(seekedKey, path)
+}
+
+func (n *parentlessInnerNode[K, V]) isOverflow(order int) bool {
+	n.countAccess()
+	return len(n.children) > order
+}
+
+func (n *parentlessInnerNode[K, V]) expandAtChild(childToRemove, left, right parentlessNode[K, V], separator K) {
+	n.countAccess()
+	i := slices.Index(n.children, childToRemove)
+	if i == -1 {
+		panic("BUG! Could not find child!")
+	}
+	n.children = slices.Delete(n.children, i, i+1)
+	n.children = slices.Insert(n.children, i, left, right)
+	n.keys = slices.Insert(n.keys, i, separator)
+}
+
+// splitAroundMedian works like innerNode.splitAroundMedian, but the moved children need not be touched.
+func (n *parentlessInnerNode[K, V]) splitAroundMedian() (*parentlessInnerNode[K, V], *parentlessInnerNode[K, V], K) {
+	n.countAccess()
+	iMedian := len(n.keys) / 2
+	medianValue := n.keys[iMedian]
+	newLeft := &parentlessInnerNode[K, V]{
+		children:      slices.Clone(n.children[:iMedian+1]), // clone to allow GC collecting n.children
+		keys:          slices.Clone(n.keys[:iMedian]),
+		accessCounter: n.accessCounter,
+	}
+	newRight := &parentlessInnerNode[K, V]{
+		children:      slices.Clone(n.children[iMedian+1:]),
+		keys:          slices.Clone(n.keys[iMedian+1:]),
+		accessCounter: n.accessCounter,
+	}
+	return newLeft, newRight, medianValue
+}
+
+func (n *parentlessInnerNode[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	fmt.Fprintf(w, "%s--\n", spaces)
+	for i, key := range n.keys {
+		n.children[i].print(w, indent+1)
+		fmt.Fprintf(w, "%s%v:\n", spaces, key)
+	}
+	n.children[len(n.children)-1].print(w, indent+1)
+	fmt.Fprintf(w, "%s--\n", spaces)
+}
+
+func (n *parentlessInnerNode[K, V]) countAccess() {
+	n.accessCounter(n)
+}
+
+////////////////////////////////////////
+// Parentless leaf node functions and methods
+////////////////////////////////////////
+
+// parentlessLeafNode is like aosLeafNode, but without the parent pointer.
+type parentlessLeafNode[K cmp.Ordered, V any] struct {
+	pairs         []pair[K, V]
+	accessCounter accessCounter
+}
+
+func newParentlessLeafNode[K cmp.Ordered, V any](ac accessCounter) *parentlessLeafNode[K, V] {
+	return &parentlessLeafNode[K, V]{
+		pairs:         []pair[K, V]{},
+		accessCounter: ac,
+	}
+}
+
+func (n *parentlessLeafNode[K, V]) findLeafNodeByKey(seekedKey K, path *[]*parentlessInnerNode[K, V]) *parentlessLeafNode[K, V] {
+	n.countAccess()
+	return n
+}
+
+func (n *parentlessLeafNode[K, V]) getValue(key K) (V, bool) {
+	n.countAccess()
+	if i := pairSlice[K, V](n.pairs).bisect(key); i == -1 || n.pairs[i].key != key {
+		var zero V
+		return zero, false
+	} else {
+		return n.pairs[i].value, true
+	}
+}
+
+func (n *parentlessLeafNode[K, V]) isOverflow(order int) bool {
+	n.countAccess()
+	return len(n.pairs) > order
+}
+
+func (n *parentlessLeafNode[K, V]) insertSorted(key K, value V) {
+	n.countAccess()
+	i := pairSlice[K, V](n.pairs).bisect(key)
+	if i == -1 {
+		i = len(n.pairs)
+	} else if n.pairs[i].key == key {
+		n.pairs[i].value = value
+		return
+	}
+	n.pairs = slices.Insert(n.pairs, i, pair[K, V]{key: key, value: value})
+}
+
+func (n *parentlessLeafNode[K, V]) splitAroundMedian() (*parentlessLeafNode[K, V], *parentlessLeafNode[K, V], K) {
+	n.countAccess()
+	median := n.pairs[len(n.pairs)/2].key
+	iMedian := pairSlice[K, V](n.pairs).bisect(median)
+	left, right := newParentlessLeafNode[K, V](n.accessCounter), newParentlessLeafNode[K, V](n.accessCounter)
+	left.pairs = append(left.pairs, n.pairs[:iMedian]...)
+	right.pairs = append(right.pairs, n.pairs[iMedian:]...)
+	return left, right, median
+}
+
+func (n *parentlessLeafNode[K, V]) print(w io.Writer, indent int) {
+	n.countAccess()
+	spaces := strings.Repeat(" ", indent)
+	for _, p := range n.pairs {
+		fmt.Fprintf(w, "%s[%v]:%v\n", spaces, p.key, p.value)
+	}
+}
+
+func (n *parentlessLeafNode[K, V]) countAccess() {
+	n.accessCounter(n)
+}
@@ -0,0 +1,15 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/btree/btreetest"
+	"testing"
+)
+
+func TestParentlessConformance(t *testing.T) {
+	btreetest.RunConformance(t, func(order int) btreetest.Tree { return btree.NewParentless[int, int](order) })
+}
+
+func TestParentlessModel(t *testing.T) {
+	btreetest.RunModel(t, func(order int) btreetest.Tree { return btree.NewParentless[int, int](order) }, "btree.NewParentless[int, int](%d)")
+}
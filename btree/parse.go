@@ -0,0 +1,118 @@
+package btree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadPrinted reads a tree of int keys and values in the format of Print. The tree is read as it is, without any
+// checks, so a damaged tree is read as the same damaged tree, to be checked with IntegrityCheck and fixed with Repair.
+// The order and the options are the ones of New.
+func ReadPrinted(r io.Reader, order int, opts ...Option) (*Btree[int, int], error) {
+	p := printedTreeParser{b: New[int, int](order, opts...)}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimLeft(scanner.Text(), " ")
+		p.lines = append(p.lines, printedLine{indent: len(scanner.Text()) - len(text), text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, p.errorf("unexpected %q", p.lines[p.i].text)
+	}
+	p.b.root = root
+	return p.b, nil
+}
+
+type printedLine struct {
+	indent int
+	text   string
+}
+
+// printedTreeParser parses the lines of Print. An inner node is "--", its children indented by one more space and
+// separated by the "key:" lines, and "--". A leaf is the "[key]:value" lines of its pairs, none for an empty leaf.
+type printedTreeParser struct {
+	b     *Btree[int, int]
+	lines []printedLine
+	// i is the index of the next line.
+	i int
+}
+
+// next returns the next line if it has the indent.
+func (p *printedTreeParser) next(indent int) (string, bool) {
+	if p.i == len(p.lines) || p.lines[p.i].indent != indent {
+		return "", false
+	}
+	return p.lines[p.i].text, true
+}
+
+// errorf returns the error of the next line.
+func (p *printedTreeParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("line %d: %w", p.i+1, fmt.Errorf(format, a...))
+}
+
+func (p *printedTreeParser) parseNode(indent int) (node[int, int], error) {
+	if text, ok := p.next(indent); ok && text == "--" {
+		p.i++
+		return p.parseInner(indent)
+	}
+	leaf := p.b.newLeafNode()
+	for text, ok := p.next(indent); ok && strings.HasPrefix(text, "["); text, ok = p.next(indent) {
+		key, value, found := strings.Cut(text[1:], "]:")
+		if !found {
+			return nil, p.errorf("expected [key]:value, got %q", text)
+		}
+		k, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, p.errorf("bad key: %w", err)
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, p.errorf("bad value: %w", err)
+		}
+		leaf.appendPairs(pair[int, int]{k, v})
+		p.i++
+	}
+	return leaf, nil
+}
+
+// parseInner parses the children and the separators of the inner node after its opening "--".
+func (p *printedTreeParser) parseInner(indent int) (node[int, int], error) {
+	inner := p.b.newInnerNode()
+	for {
+		child, err := p.parseNode(indent + 1)
+		if err != nil {
+			return nil, err
+		}
+		inner.children = append(inner.children, child)
+		child.setParent(inner)
+		text, ok := p.next(indent)
+		if !ok {
+			return nil, p.errorf("expected a separator or -- at indent %d", indent)
+		}
+		if text == "--" {
+			p.i++
+			// The counts and the aggregates are not printed, so they are computed from the children.
+			p.b.resummarize(inner)
+			return inner, nil
+		}
+		key, found := strings.CutSuffix(text, ":")
+		if !found {
+			return nil, p.errorf("expected key:, got %q", text)
+		}
+		k, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, p.errorf("bad separator: %w", err)
+		}
+		inner.keys = append(inner.keys, k)
+		p.i++
+	}
+}
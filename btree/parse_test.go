@@ -0,0 +1,71 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPrinted(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for _, leafLayout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA} {
+		for _, order := range []int{2, 3, 5, 10} {
+			for _, n := range []int{0, 1, 100} {
+				t.Run(fmt.Sprintf("%s order %d n %d", leafLayout, order, n), func(t *testing.T) {
+					b := btree.New[int, int](order, btree.WithLeafLayout(leafLayout))
+					for _, v := range r.Perm(n) {
+						b.Insert(v, -v)
+					}
+					printed := &bytes.Buffer{}
+					b.Print(printed)
+					read, err := btree.ReadPrinted(strings.NewReader(printed.String()), order, btree.WithLeafLayout(leafLayout))
+					assert.NoError(t, err)
+					assert.NoError(t, read.IntegrityCheck())
+					reprinted := &bytes.Buffer{}
+					read.Print(reprinted)
+					assert.Equal(t, printed.String(), reprinted.String())
+				})
+			}
+		}
+	}
+}
+
+func TestReadPrintedDamaged(t *testing.T) {
+	// The separator 15 of the root is smaller than the key 20 of its left child.
+	printed := `--
+ [10]:1
+ [20]:2
+15:
+ [30]:3
+ [40]:4
+--
+`
+	b, err := btree.ReadPrinted(strings.NewReader(printed), 2)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(b.IntegrityCheck(), btree.ErrSeparatorBounds))
+	report := b.Repair()
+	assert.NotEmpty(t, report.Violations)
+	assert.NoError(t, b.IntegrityCheck())
+	assert.Equal(t, []int{10, 20, 30, 40}, treeKeys(b))
+}
+
+func TestReadPrintedErrors(t *testing.T) {
+	for name, printed := range map[string]string{
+		"bad pair":      "[10]1\n",
+		"bad key":       "[x]:1\n",
+		"bad separator": "--\n [10]:1\nx:\n [20]:2\n--\n",
+		"unclosed":      "--\n [10]:1\n",
+		"trailing":      "[10]:1\n--\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := btree.ReadPrinted(strings.NewReader(printed), 2)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package btree
+
+// The order statistics. They descend from the root and skip the sub-trees left of the path by their sizes, so they
+// take O(log n) with WithSubtreeCounts, and O(n) without it, when the sizes are counted from the leaves.
+
+// Len returns the number of the pairs in the tree.
+func (b *Btree[K, V]) Len() int {
+	return b.size(b.root)
+}
+
+// Rank returns the number of the keys smaller than the key, which is the index of the key in the ascending order if
+// it is present.
+func (b *Btree[K, V]) Rank(key K) int {
+	rank := 0
+	n := b.root
+	for {
+		inner, ok := n.(*innerNode[K, V])
+		if !ok {
+			break
+		}
+		inner.countAccess()
+		i := inner.childIndex(key)
+		rank += b.sizeOfChildren(inner, i)
+		n = inner.children[i]
+	}
+	leaf := n.(leafNode[K, V])
+	leaf.countAccess()
+	if i := leaf.bisect(key); i != -1 {
+		return rank + i
+	}
+	return rank + leaf.len()
+}
+
+// Select returns the pair with the i-th smallest key, counting from 0, or false if i is not in [0, Len()).
+func (b *Btree[K, V]) Select(i int) (K, V, bool) {
+	var zeroK K
+	var zeroV V
+	if i < 0 {
+		return zeroK, zeroV, false
+	}
+	n := b.root
+	for {
+		inner, ok := n.(*innerNode[K, V])
+		if !ok {
+			break
+		}
+		inner.countAccess()
+		j := 0
+		for ; j < len(inner.children)-1; j++ {
+			size := b.sizeOfChild(inner, j)
+			if i < size {
+				break
+			}
+			i -= size
+		}
+		n = inner.children[j]
+	}
+	leaf := n.(leafNode[K, V])
+	leaf.countAccess()
+	if i >= leaf.len() {
+		return zeroK, zeroV, false
+	}
+	return leaf.keyAt(i), leaf.valueAt(i), true
+}
+
+// CountRange returns the number of the keys in [lo, hi) range, the keys Scan calls its function for.
+func (b *Btree[K, V]) CountRange(lo, hi K) int {
+	if hi <= lo {
+		return 0
+	}
+	return b.Rank(hi) - b.Rank(lo)
+}
@@ -0,0 +1,181 @@
+package btree
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// LayoutOrder is the order in which Btree.Relayout places the nodes in memory.
+type LayoutOrder int
+
+const (
+	// LayoutDFS places the nodes in depth-first pre-order, so a node is followed by its first sub-tree.
+	LayoutDFS LayoutOrder = iota
+	// LayoutBFS places the nodes level by level, from the root down to the leaves.
+	LayoutBFS
+	// LayoutLeavesFirst places the nodes level by level, from the leaves up to the root.
+	LayoutLeavesFirst
+)
+
+func (o LayoutOrder) String() string {
+	switch o {
+	case LayoutDFS:
+		return "dfs"
+	case LayoutBFS:
+		return "bfs"
+	case LayoutLeavesFirst:
+		return "leaves-first"
+	}
+	return "unknown"
+}
+
+func ParseLayoutOrder(s string) (LayoutOrder, error) {
+	for _, o := range []LayoutOrder{LayoutDFS, LayoutBFS, LayoutLeavesFirst} {
+		if o.String() == s {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown layout order %q", s)
+}
+
+// Relayout reallocates all the nodes so they are contiguous in memory, in the given order. Inner nodes and leafs are
+// of different types, so they are in two arrays, and each kind of node arrays (keys, children, pairs) is in an array of
+// its own, in the same order. The node arrays keep room for the overflow before split, so the tree stays mutable and
+// inserts do not move the nodes out of the contiguous arrays until the nodes split.
+func (b *Btree[K, V]) Relayout(order LayoutOrder) {
+	nodes := b.nodesInLayoutOrder(order)
+	nInner, nLeaf := 0, 0
+	for _, n := range nodes {
+		if _, ok := n.(*innerNode[K, V]); ok {
+			nInner++
+		} else {
+			nLeaf++
+		}
+	}
+	inners := make([]innerNode[K, V], 0, nInner)
+	// Only one of the leaf arrays is used, the one of the layout of the tree.
+	aosLeafs := make([]aosLeafNode[K, V], 0, nLeaf)
+	soaLeafs := make([]soaLeafNode[K, V], 0, nLeaf)
+	keysLeafs := make([]keysLeafNode[K, V], 0, nLeaf)
+	// An inner node can have up to order keys and order+1 children before split. A leaf can have up to leafOrder+1
+	// pairs.
+	keysSlab := make([]K, 0, nInner*b.order+nLeaf*(b.leafOrder+1))
+	childrenSlab := make([]node[K, V], 0, nInner*(b.order+1))
+	pairsSlab := make([]pair[K, V], 0, nLeaf*(b.leafOrder+1))
+	valuesSlab := make([]V, 0, nLeaf*(b.leafOrder+1))
+
+	moved := make(map[node[K, V]]node[K, V], len(nodes))
+	for _, n := range nodes {
+		switch t := n.(type) {
+		case *innerNode[K, V]:
+			inners = append(inners, *t)
+			m := &inners[len(inners)-1]
+			m.keys = carve(&keysSlab, t.keys, b.order)
+			m.children = carve(&childrenSlab, t.children, b.order+1)
+			if t.owner != b.owner && t.summaries != nil {
+				m.summaries = t.summaries.clone()
+			}
+			m.owner = b.owner
+			moved[n] = m
+		case *aosLeafNode[K, V]:
+			aosLeafs = append(aosLeafs, *t)
+			m := &aosLeafs[len(aosLeafs)-1]
+			m.pairs = carve(&pairsSlab, t.pairs, b.leafOrder+1)
+			m.owner = b.owner
+			moved[n] = m
+		case *soaLeafNode[K, V]:
+			soaLeafs = append(soaLeafs, *t)
+			m := &soaLeafs[len(soaLeafs)-1]
+			m.keys = carve(&keysSlab, t.keys, b.leafOrder+1)
+			m.values = carve(&valuesSlab, t.values, b.leafOrder+1)
+			m.owner = b.owner
+			moved[n] = m
+		case *keysLeafNode[K, V]:
+			keysLeafs = append(keysLeafs, *t)
+			m := &keysLeafs[len(keysLeafs)-1]
+			m.keys = carve(&keysSlab, t.keys, b.leafOrder+1)
+			m.owner = b.owner
+			moved[n] = m
+		}
+	}
+	// The parent pointers of the nodes shared with a clone are not kept, see Clone, so the parents are set from the
+	// children. All the moved nodes are owned by the tree, none is shared anymore.
+	for i := range inners {
+		m := &inners[i]
+		for j, c := range m.children {
+			m.children[j] = moved[c]
+			relinkParent(m.children[j], m)
+		}
+	}
+	b.root = moved[b.root]
+	relinkParent(b.root, nil)
+	b.shared = false
+}
+
+// relinkParent sets the parent of the node like setParent, but without counting the access.
+func relinkParent[K cmp.Ordered, V any](n node[K, V], parent *innerNode[K, V]) {
+	switch t := n.(type) {
+	case *innerNode[K, V]:
+		t.parent = parent
+	case *aosLeafNode[K, V]:
+		t.parent = parent
+	case *soaLeafNode[K, V]:
+		t.parent = parent
+	case *keysLeafNode[K, V]:
+		t.parent = parent
+	}
+}
+
+// carve appends src to the slab and returns the appended part with given capacity, that is reserved in the slab.
+func carve[T any](slab *[]T, src []T, capacity int) []T {
+	if assertionsEnabled {
+		assert(len(src) <= capacity, "slice of len %d does not fit capacity %d", len(src), capacity)
+	}
+	start := len(*slab)
+	*slab = append(*slab, src...)
+	*slab = (*slab)[:start+capacity]
+	return (*slab)[start : start+len(src) : start+capacity]
+}
+
+// nodesInLayoutOrder returns all the nodes of the tree. It does not count the accesses, since it is not a part of the
+// measured operations.
+func (b *Btree[K, V]) nodesInLayoutOrder(order LayoutOrder) []node[K, V] {
+	switch order {
+	case LayoutDFS:
+		nodes := []node[K, V]{}
+		var visit func(n node[K, V])
+		visit = func(n node[K, V]) {
+			nodes = append(nodes, n)
+			if inner, ok := n.(*innerNode[K, V]); ok {
+				for _, c := range inner.children {
+					visit(c)
+				}
+			}
+		}
+		visit(b.root)
+		return nodes
+	case LayoutBFS, LayoutLeavesFirst:
+		levels := [][]node[K, V]{{b.root}}
+		for {
+			next := []node[K, V]{}
+			for _, n := range levels[len(levels)-1] {
+				if inner, ok := n.(*innerNode[K, V]); ok {
+					next = append(next, inner.children...)
+				}
+			}
+			if len(next) == 0 {
+				break
+			}
+			levels = append(levels, next)
+		}
+		nodes := []node[K, V]{}
+		for i := range levels {
+			if order == LayoutLeavesFirst {
+				i = len(levels) - 1 - i
+			}
+			nodes = append(nodes, levels[i]...)
+		}
+		return nodes
+	}
+	panic(fmt.Sprintf("unknown layout order %d", order))
+}
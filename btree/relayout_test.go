@@ -0,0 +1,40 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayout(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	values := r.Perm(2000)
+	for _, layout := range []btree.LayoutOrder{btree.LayoutDFS, btree.LayoutBFS, btree.LayoutLeavesFirst} {
+		for _, leafLayout := range []btree.LeafLayout{btree.LeafLayoutAoS, btree.LeafLayoutSoA} {
+			for _, order := range []int{2, 3, 5, 10} {
+				t.Run(fmt.Sprintf("%s %s order %d", layout, leafLayout, order), func(t *testing.T) {
+					b := btree.New[int, int](order, btree.WithLeafLayout(leafLayout))
+					for _, v := range values[:1000] {
+						b.Insert(v, v)
+					}
+					b.Relayout(layout)
+					assert.NoError(t, b.IntegrityCheck())
+					for _, v := range values[:1000] {
+						assertFound(t, b, v, v)
+					}
+					// The tree must stay mutable after relayout.
+					for _, v := range values[1000:] {
+						b.Insert(v, v)
+					}
+					assert.NoError(t, b.IntegrityCheck())
+					for _, v := range values {
+						assertFound(t, b, v, v)
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package btree
+
+import (
+	"cmp"
+	"slices"
+)
+
+// RepairReport is what Repair found and changed.
+type RepairReport[K cmp.Ordered] struct {
+	// Violations are the violations of the tree before the repair, see IntegrityReport.
+	Violations []*Violation[K]
+	// Pairs is the number of the pairs in the repaired tree.
+	Pairs int
+	// Dropped are the keys of the dropped duplicate pairs. Of the pairs with the same key, Repair keeps the first one
+	// within the separator bounds, or the first one if there is none.
+	Dropped []K
+	// Moved are the keys that were outside of the separator bounds, and were kept.
+	Moved []K
+	// SharedNodes is the number of the nodes reachable more than once, whose pairs were salvaged only once.
+	SharedNodes int
+}
+
+// Repair rebuilds the tree if IntegrityCheck fails. It salvages the pairs of all the leaves reachable from the root,
+// sorts them and drops the duplicate keys, and builds a new tree from them bottom-up. A valid tree is left as it is.
+func (b *Btree[K, V]) Repair() *RepairReport[K] {
+	report := &RepairReport[K]{Violations: b.IntegrityReport().Violations}
+	if len(report.Violations) == 0 {
+		return report
+	}
+	salvaged := b.salvage(report)
+	slices.SortStableFunc(salvaged, func(a, b salvagedPair[K, V]) int {
+		return cmp.Compare(a.key, b.key)
+	})
+	pairs := make([]pair[K, V], 0, len(salvaged))
+	for i := 0; i < len(salvaged); {
+		j := i + 1
+		for j < len(salvaged) && salvaged[j].key == salvaged[i].key {
+			j++
+		}
+		kept := salvaged[i]
+		if k := slices.IndexFunc(salvaged[i:j], func(p salvagedPair[K, V]) bool { return p.inBounds }); k != -1 {
+			kept = salvaged[i+k]
+		}
+		for range j - i - 1 {
+			report.Dropped = append(report.Dropped, kept.key)
+		}
+		if !kept.inBounds {
+			report.Moved = append(report.Moved, kept.key)
+		}
+		pairs = append(pairs, kept.pair)
+		i = j
+	}
+	b.buildFromSorted(pairs)
+	report.Pairs = len(pairs)
+	return report
+}
+
+type salvagedPair[K cmp.Ordered, V any] struct {
+	pair[K, V]
+	// inBounds is true if the key was within the separator bounds of its leaf.
+	inBounds bool
+}
+
+// salvage returns the pairs of the leaves reachable from the root, in the depth-first order. Every node is visited
+// once, so it ends on the cycles too.
+func (b *Btree[K, V]) salvage(report *RepairReport[K]) []salvagedPair[K, V] {
+	pairs := []salvagedPair[K, V]{}
+	visited := map[node[K, V]]bool{}
+	var visit func(n node[K, V], lo, hi *K)
+	visit = func(n node[K, V], lo, hi *K) {
+		if visited[n] {
+			report.SharedNodes++
+			return
+		}
+		visited[n] = true
+		switch t := n.(type) {
+		case leafNode[K, V]:
+			for i := range t.len() {
+				key := t.keyAt(i)
+				pairs = append(pairs, salvagedPair[K, V]{pair[K, V]{key, t.valueAt(i)}, inBounds(key, lo, hi)})
+			}
+		case *innerNode[K, V]:
+			for i, child := range t.children {
+				childLo, childHi := childBounds(t, i, lo, hi)
+				visit(child, childLo, childHi)
+			}
+		}
+	}
+	visit(b.root, nil, nil)
+	return pairs
+}
+
+// buildFromSorted replaces the content of the tree with the pairs, sorted by unique keys. It builds the tree bottom-up,
+// with the items of each level spread evenly among as few nodes as possible, so the nodes are at least half full.
+func (b *Btree[K, V]) buildFromSorted(pairs []pair[K, V]) {
+	b.buildFromSortedFunc(len(pairs), func(i int) K { return pairs[i].key }, func(leaf leafNode[K, V], lo, hi int) {
+		leaf.appendPairs(pairs[lo:hi]...)
+	})
+}
+
+// buildFromSortedKeys is buildFromSorted of the keys with the zero values, for the sets.
+func (b *Btree[K, V]) buildFromSortedKeys(keys []K) {
+	b.buildFromSortedFunc(len(keys), func(i int) K { return keys[i] }, func(leaf leafNode[K, V], lo, hi int) {
+		leaf.appendKeys(keys[lo:hi]...)
+	})
+}
+
+// buildFromSortedFunc builds the tree of n items, where key returns the key of the i-th item, and fill appends the
+// items from lo to hi to a leaf.
+func (b *Btree[K, V]) buildFromSortedFunc(n int, key func(i int) K, fill func(leaf leafNode[K, V], lo, hi int)) {
+	level := []node[K, V]{}
+	// minKeys are the smallest keys of the sub-trees of the level, the separators of the level above.
+	minKeys := []K{}
+	bounds := evenSplits(n, b.leafOrder)
+	for i := range len(bounds) - 1 {
+		leaf := b.newLeafNode()
+		fill(leaf, bounds[i], bounds[i+1])
+		level = append(level, leaf)
+		minKeys = append(minKeys, key(bounds[i]))
+	}
+	if len(level) == 0 {
+		b.root = b.newLeafNode()
+		return
+	}
+	for len(level) > 1 {
+		nextLevel, nextMinKeys := []node[K, V]{}, []K{}
+		bounds := evenSplits(len(level), b.order)
+		for i := range len(bounds) - 1 {
+			lo, hi := bounds[i], bounds[i+1]
+			inner := b.newInnerNode()
+			inner.children = append(inner.children, level[lo:hi]...)
+			inner.keys = append(inner.keys, minKeys[lo+1:hi]...)
+			for _, c := range inner.children {
+				c.setParent(inner)
+			}
+			b.resummarize(inner)
+			nextLevel = append(nextLevel, inner)
+			nextMinKeys = append(nextMinKeys, minKeys[lo])
+		}
+		level, minKeys = nextLevel, nextMinKeys
+	}
+	level[0].setParent(nil)
+	b.root = level[0]
+	// All the nodes are new, none is shared with a clone.
+	b.shared = false
+}
+
+// evenSplits returns the bounds of the ⌈n/size⌉ ranges that split [0, n) evenly, the i-th range is from bounds[i] to
+// bounds[i+1]. With at least two ranges, each has at least ⌈size/2⌉ items.
+func evenSplits(n, size int) []int {
+	k := (n + size - 1) / size
+	bounds := make([]int, k+1)
+	for i := range bounds[1:] {
+		bounds[i+1] = (i + 1) * n / k
+	}
+	return bounds
+}
@@ -0,0 +1,39 @@
+package btree
+
+import "testing"
+
+func TestBuildFromSorted(t *testing.T) {
+	for _, layout := range []LeafLayout{LeafLayoutAoS, LeafLayoutSoA} {
+		for _, order := range []int{2, 3, 4, 5, 10} {
+			for _, leafOrder := range []int{1, 2, order, 2 * order} {
+				for _, n := range []int{0, 1, 2, 3, 7, 20, 99, 1000} {
+					b := New[int, int](order, WithLeafOrder(leafOrder), WithLeafLayout(layout))
+					pairs := []pair[int, int]{}
+					for i := range n {
+						pairs = append(pairs, pair[int, int]{i, i})
+					}
+					b.buildFromSorted(pairs)
+					if err := b.IntegrityCheck(); err != nil {
+						t.Fatalf("%s order %d leaf order %d n %d: %v", layout, order, leafOrder, n, err)
+					}
+					if keys := leafKeys(b); len(keys) != n {
+						t.Fatalf("%s order %d leaf order %d n %d: got %d keys", layout, order, leafOrder, n, len(keys))
+					}
+				}
+			}
+		}
+	}
+}
+
+// leafKeys returns the keys of all the leaves of the tree, in the depth-first order.
+func leafKeys(b *Btree[int, int]) []int {
+	keys := []int{}
+	for _, n := range b.nodesInLayoutOrder(LayoutDFS) {
+		if leaf, ok := n.(leafNode[int, int]); ok {
+			for i := range leaf.len() {
+				keys = append(keys, leaf.keyAt(i))
+			}
+		}
+	}
+	return keys
+}
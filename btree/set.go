@@ -0,0 +1,175 @@
+package btree
+
+import "cmp"
+
+// Set is an ordered set of keys, a Btree with no values. Its leaves have a layout of their own, they store only the
+// keys. With LeafLayoutAoS, a pair of a key and struct{} would take as much memory as a pair of two keys, since a
+// struct ending with a zero-size field is padded, and with LeafLayoutSoA, every leaf would have a slice of the values.
+//
+// The set operations walk both sets in ascending order of the keys, leaf by leaf, like a merge, and build the result
+// bottom-up from the sorted keys, in O(n + m) instead of O(n log n) of repeated inserts.
+type Set[K cmp.Ordered] struct {
+	tree *Btree[K, struct{}]
+}
+
+// NewSet returns an empty set. The options are the ones of New, except WithLeafLayout, on which it panics.
+func NewSet[K ~int](order int, opts ...Option) *Set[K] {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.leafLayoutSet {
+		panic("a set stores only the keys, WithLeafLayout is not supported")
+	}
+	return &Set[K]{tree: New[K, struct{}](order, append(opts, WithLeafLayout(leafLayoutKeys))...)}
+}
+
+func (s *Set[K]) Insert(key K) {
+	s.tree.Insert(key, struct{}{})
+}
+
+func (s *Set[K]) Contains(key K) bool {
+	_, ok := s.tree.Find(key)
+	return ok
+}
+
+// Len returns the number of the keys, in O(log n) with WithSubtreeCounts, and O(n) otherwise.
+func (s *Set[K]) Len() int {
+	return s.tree.Len()
+}
+
+// Ascend calls fun for all the keys in ascending order, until fun returns false.
+func (s *Set[K]) Ascend(fun func(key K) bool) {
+	s.tree.Ascend(func(key K, _ struct{}) bool {
+		return fun(key)
+	})
+}
+
+// Clone returns a copy of the set in O(1), see Btree.Clone.
+func (s *Set[K]) Clone() *Set[K] {
+	return &Set[K]{tree: s.tree.Clone()}
+}
+
+// IntegrityCheck checks the tree of the set, see Btree.IntegrityCheck.
+func (s *Set[K]) IntegrityCheck() error {
+	return s.tree.IntegrityCheck()
+}
+
+// SplitAt returns the set of the keys smaller than the key and the set of the rest of the keys, in O(log n), see
+// Btree.SplitAt.
+func (s *Set[K]) SplitAt(key K) (left, right *Set[K]) {
+	l, r := s.tree.SplitAt(key)
+	return &Set[K]{tree: l}, &Set[K]{tree: r}
+}
+
+// JoinSets returns the set of the keys of both sets, in O(log n), see Join.
+func JoinSets[K cmp.Ordered](left, right *Set[K]) (*Set[K], error) {
+	t, err := Join(left.tree, right.tree)
+	if err != nil {
+		return nil, err
+	}
+	return &Set[K]{tree: t}, nil
+}
+
+// DeleteRange deletes the keys in [lo, hi) range, in O(log n), see Btree.DeleteRange.
+func (s *Set[K]) DeleteRange(lo, hi K) {
+	s.tree.DeleteRange(lo, hi)
+}
+
+// Union returns the set of the keys in either set. The result has the configuration of s.
+func (s *Set[K]) Union(other *Set[K]) *Set[K] {
+	return s.merge(other, true, true, true)
+}
+
+// Intersect returns the set of the keys in both sets. The result has the configuration of s.
+func (s *Set[K]) Intersect(other *Set[K]) *Set[K] {
+	return s.merge(other, false, true, false)
+}
+
+// Difference returns the set of the keys of s that are not in the other set. The result has the configuration of s.
+func (s *Set[K]) Difference(other *Set[K]) *Set[K] {
+	return s.merge(other, true, false, false)
+}
+
+// IsSubset returns true if all the keys of s are in the other set. It stops at the first key that is not.
+func (s *Set[K]) IsSubset(other *Set[K]) bool {
+	a, b := newCursor(s.tree), newCursor(other.tree)
+	hasB := b.next()
+	for a.next() {
+		for hasB && b.key() < a.key() {
+			hasB = b.next()
+		}
+		if !hasB || b.key() != a.key() {
+			return false
+		}
+	}
+	return true
+}
+
+// merge walks both sets and returns the set of the keys only in s if onlyS, in both sets if both, and only in the
+// other set if onlyOther.
+func (s *Set[K]) merge(other *Set[K], onlyS, both, onlyOther bool) *Set[K] {
+	// The capacity is the largest possible size of the result. Len is O(n) without WithSubtreeCounts, like the merge.
+	var capacity int
+	switch n, m := s.Len(), other.Len(); {
+	case onlyS && onlyOther:
+		capacity = n + m
+	case onlyS:
+		capacity = n
+	case onlyOther:
+		capacity = m
+	default:
+		capacity = min(n, m)
+	}
+	keys := make([]K, 0, capacity)
+	a, b := newCursor(s.tree), newCursor(other.tree)
+	hasA, hasB := a.next(), b.next()
+	for hasA || hasB {
+		switch {
+		case !hasB || hasA && a.key() < b.key():
+			if onlyS {
+				keys = append(keys, a.key())
+			}
+			hasA = a.next()
+		case !hasA || b.key() < a.key():
+			if onlyOther {
+				keys = append(keys, b.key())
+			}
+			hasB = b.next()
+		default:
+			if both {
+				keys = append(keys, a.key())
+			}
+			hasA, hasB = a.next(), b.next()
+		}
+		if !onlyS && !hasB || !onlyOther && !hasA {
+			// Nothing more can be in the result.
+			break
+		}
+	}
+	result := s.tree.configCopy()
+	result.buildFromSortedKeys(keys)
+	return &Set[K]{tree: result}
+}
+
+// configCopy returns a tree with the configuration of the tree and no root, which the caller sets. The nodes of the copy
+// are allocated on the heap, even with WithShuffledAllocation, so a copy does not preallocate a chunk of nodes.
+func (b *Btree[K, V]) configCopy() *Btree[K, V] {
+	c := *b
+	c.owner, c.shared = newOwner(), false
+	c.allocator = nil
+	if b.paranoid != nil {
+		c.paranoid = newParanoidChecks(b.paranoid.level, b.paranoid.sampleRate)
+	}
+	c.root = nil
+	return &c
+}
+
+// NewSetMergeIterator returns a MergeIterator of the keys of the sets, see NewMergeIterator.
+func NewSetMergeIterator[K cmp.Ordered](sets ...*Set[K]) *MergeIterator[K, struct{}] {
+	trees := make([]*Btree[K, struct{}], len(sets))
+	for i, s := range sets {
+		trees[i] = s.tree
+	}
+	return NewMergeIterator(trees...)
+}
@@ -0,0 +1,163 @@
+package btree_test
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOperations(t *testing.T) {
+	for _, order := range []int{2, 3, 10} {
+		for _, sizes := range [][2]int{{0, 0}, {0, 10}, {10, 0}, {1, 1}, {100, 100}, {1000, 50}, {50, 1000}} {
+			t.Run(fmt.Sprintf("order %d sizes %v", order, sizes), func(t *testing.T) {
+				r := rand.New(rand.NewSource(int64(order)))
+				a, b := btree.NewSet[int](order), btree.NewSet[int](order)
+				inA, inB := map[int]bool{}, map[int]bool{}
+				for range sizes[0] {
+					key := r.Intn(2 * (sizes[0] + sizes[1]))
+					a.Insert(key)
+					inA[key] = true
+				}
+				for range sizes[1] {
+					key := r.Intn(2 * (sizes[0] + sizes[1]))
+					b.Insert(key)
+					inB[key] = true
+				}
+
+				expect := func(pred func(key int) bool) []int {
+					keys := []int{}
+					for key := range 2 * (sizes[0] + sizes[1]) {
+						if pred(key) {
+							keys = append(keys, key)
+						}
+					}
+					return keys
+				}
+				for _, tc := range []struct {
+					name     string
+					set      *btree.Set[int]
+					expected []int
+				}{
+					{"union", a.Union(b), expect(func(key int) bool { return inA[key] || inB[key] })},
+					{"intersect", a.Intersect(b), expect(func(key int) bool { return inA[key] && inB[key] })},
+					{"difference", a.Difference(b), expect(func(key int) bool { return inA[key] && !inB[key] })},
+					{"reverse difference", b.Difference(a), expect(func(key int) bool { return inB[key] && !inA[key] })},
+				} {
+					assert.NoError(t, tc.set.IntegrityCheck(), tc.name)
+					assert.Equal(t, tc.expected, setKeys(tc.set), tc.name)
+					assert.Equal(t, len(tc.expected), tc.set.Len(), tc.name)
+				}
+
+				assert.Equal(t, len(expect(func(key int) bool { return inA[key] && !inB[key] })) == 0, a.IsSubset(b))
+				assert.True(t, a.Intersect(b).IsSubset(a))
+				assert.True(t, a.IsSubset(a.Union(b)))
+				assert.True(t, a.Difference(b).IsSubset(a))
+				// The operands are not changed.
+				assert.Equal(t, expect(func(key int) bool { return inA[key] }), setKeys(a))
+				assert.Equal(t, expect(func(key int) bool { return inB[key] }), setKeys(b))
+			})
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := btree.NewSet[int](3, btree.WithSubtreeCounts())
+	for _, key := range []int{5, 1, 3, 5} {
+		s.Insert(key)
+	}
+	assert.True(t, s.Contains(3))
+	assert.False(t, s.Contains(2))
+	assert.Equal(t, 3, s.Len())
+	clone := s.Clone()
+	s.Insert(2)
+	assert.Equal(t, []int{1, 2, 3, 5}, setKeys(s))
+	assert.Equal(t, []int{1, 3, 5}, setKeys(clone))
+	assert.NoError(t, s.IntegrityCheck())
+	assert.Panics(t, func() { btree.NewSet[int](3, btree.WithLeafLayout(btree.LeafLayoutAoS)) })
+}
+
+func TestSetSplitAtJoin(t *testing.T) {
+	s := btree.NewSet[int](3, btree.WithSubtreeCounts())
+	for _, key := range rand.New(rand.NewSource(0)).Perm(300) {
+		s.Insert(key)
+	}
+	left, right := s.SplitAt(100)
+	assert.NoError(t, left.IntegrityCheck())
+	assert.NoError(t, right.IntegrityCheck())
+	assert.Equal(t, 100, left.Len())
+	assert.Equal(t, 200, right.Len())
+	assert.Equal(t, 99, setKeys(left)[99])
+	assert.Equal(t, 100, setKeys(right)[0])
+
+	joined, err := btree.JoinSets(left, right)
+	assert.NoError(t, err)
+	assert.NoError(t, joined.IntegrityCheck())
+	assert.Equal(t, setKeys(s), setKeys(joined))
+	_, err = btree.JoinSets(right, left)
+	assert.Error(t, err)
+
+	joined.DeleteRange(50, 250)
+	assert.NoError(t, joined.IntegrityCheck())
+	assert.Equal(t, 100, joined.Len())
+	assert.Equal(t, 300, s.Len())
+}
+
+func TestMergeIterator(t *testing.T) {
+	for _, k := range []int{0, 1, 2, 5} {
+		r := rand.New(rand.NewSource(int64(k)))
+		trees := []*btree.Btree[int, int]{}
+		type sourced struct{ key, value, source int }
+		expected := []sourced{}
+		for i := range k {
+			b := btree.New[int, int](3, btree.WithLeafLayout(btree.LeafLayoutSoA))
+			for range r.Intn(300) {
+				key := r.Intn(500)
+				b.Insert(key, 1000*i+key)
+			}
+			b.Ascend(func(key, value int) bool {
+				expected = append(expected, sourced{key, value, i})
+				return true
+			})
+			trees = append(trees, b)
+		}
+		slices.SortStableFunc(expected, func(a, b sourced) int { return a.key - b.key })
+
+		actual := []sourced{}
+		it := btree.NewMergeIterator(trees...)
+		for it.Next() {
+			actual = append(actual, sourced{it.Key(), it.Value(), it.Source()})
+		}
+		assert.False(t, it.Next())
+		assert.Equal(t, expected, actual, "%d trees", k)
+	}
+}
+
+func TestSetMergeIterator(t *testing.T) {
+	a, b := btree.NewSet[int](3), btree.NewSet[int](3)
+	for i := range 10 {
+		a.Insert(2 * i)
+		b.Insert(3 * i)
+	}
+	keys := []int{}
+	it := btree.NewSetMergeIterator(a, b)
+	for it.Next() {
+		if len(keys) == 0 || keys[len(keys)-1] != it.Key() {
+			keys = append(keys, it.Key())
+		}
+	}
+	assert.Equal(t, setKeys(a.Union(b)), keys)
+}
+
+// setKeys returns all the keys of the set in ascending order.
+func setKeys(s *btree.Set[int]) []int {
+	keys := []int{}
+	s.Ascend(func(key int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
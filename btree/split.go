@@ -0,0 +1,312 @@
+package btree
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// The structural surgery of SplitAt and Join. Both work on the pieces of the trees, the sub-trees with their heights,
+// where nil is an empty piece. The top node of a piece may be less than half full, like a root, but all the nodes below
+// it are valid. concat glues two pieces at the level of the lower one, which costs O(difference of the heights), so
+// splitting a tree into pieces and gluing them back costs O(height) in total. The nodes shared with other trees are
+// copied before they are modified, see Clone, so the source trees are not changed. Every inner node built or modified
+// on the way is owned by the tree that builds it, and adopt sets the parent pointers of its owned children and its
+// summaries.
+
+// SplitAt returns the tree of the keys smaller than the key and the tree of the rest of the keys, in O(log n). The tree
+// is not changed, the three trees share the nodes like after Clone.
+func (b *Btree[K, V]) SplitAt(key K) (left, right *Btree[K, V]) {
+	left, right = b.Clone(), b.Clone()
+	b.splitInto(key, left, right)
+	if b.paranoid != nil {
+		left.paranoidCheckTree("split at %v", key)
+		right.paranoidCheckTree("split at %v", key)
+	}
+	return left, right
+}
+
+// Join returns the tree of the pairs of both trees, in O(log n). All the keys of the left tree must be smaller than
+// the keys of the right tree, and the trees must have the same configuration: the orders, the leaf layout, the counts,
+// and the monoid, which only the left tree's is used of. The trees are not changed, the result shares the nodes with
+// them like after Clone.
+func Join[K cmp.Ordered, V any](left, right *Btree[K, V]) (*Btree[K, V], error) {
+	if left.order != right.order || left.leafOrder != right.leafOrder {
+		return nil, fmt.Errorf("cannot join trees of different orders, %d/%d and %d/%d", left.order, left.leafOrder, right.order, right.leafOrder)
+	}
+	if left.leafLayout != right.leafLayout {
+		return nil, fmt.Errorf("cannot join trees of different leaf layouts, %s and %s", left.leafLayout, right.leafLayout)
+	}
+	if left.subtreeCounts != right.subtreeCounts || (left.monoid == nil) != (right.monoid == nil) {
+		return nil, fmt.Errorf("cannot join trees with different summaries of the sub-trees")
+	}
+	if !left.isEmpty() && !right.isEmpty() {
+		if maxKey, minKey := left.maxKey(), right.minKey(); maxKey >= minKey {
+			return nil, fmt.Errorf("keys of the left tree must be smaller than the keys of the right tree, got %v and %v", maxKey, minKey)
+		}
+	}
+	t := left.Clone()
+	t.join(right.Clone())
+	if t.paranoid != nil {
+		t.paranoidCheckTree("join")
+	}
+	return t, nil
+}
+
+// DeleteRange deletes the keys in [lo, hi) range, the ones Scan calls its function for, in O(log n).
+func (b *Btree[K, V]) DeleteRange(lo, hi K) {
+	if hi <= lo {
+		return
+	}
+	left, rest := b.Clone(), b.Clone()
+	b.splitInto(lo, left, rest)
+	deleted, right := rest.Clone(), rest.Clone()
+	rest.splitInto(hi, deleted, right)
+	left.join(right)
+	b.root, b.owner = left.root, left.owner
+	if b.paranoid != nil {
+		b.paranoidCheckTree("delete of [%v, %v)", lo, hi)
+	}
+}
+
+// splitInto replaces the roots of left and right, clones of the tree, with the keys smaller than the key and the rest
+// of the keys.
+func (b *Btree[K, V]) splitInto(key K, left, right *Btree[K, V]) {
+	l, _, r, _ := splitNode(left, right, b.root, b.leftmostLeafDepth(), key)
+	left.setRootPiece(l)
+	right.setRootPiece(r)
+}
+
+// join appends the pairs of the right tree to the tree, all its keys must be larger.
+func (b *Btree[K, V]) join(right *Btree[K, V]) {
+	if right.isEmpty() {
+		return
+	}
+	var l node[K, V]
+	if !b.isEmpty() {
+		l = b.root
+	}
+	root, _ := b.concat(l, b.leftmostLeafDepth(), right.minKey(), right.root, right.leftmostLeafDepth())
+	b.setRootPiece(root)
+}
+
+// setRootPiece makes the piece the root. With the order 2, a non-root inner node is valid with one child, so the top
+// of a piece can have one child, which the root must not. The root is owned by the tree, with no parent.
+func (b *Btree[K, V]) setRootPiece(n node[K, V]) {
+	if n == nil {
+		n = b.newLeafNode()
+	}
+	for inner, ok := n.(*innerNode[K, V]); ok && len(inner.children) == 1; inner, ok = n.(*innerNode[K, V]) {
+		n = inner.children[0]
+	}
+	n = b.mutable(n, nil)
+	n.setParent(nil)
+	b.root = n
+}
+
+func (b *Btree[K, V]) isEmpty() bool {
+	leaf, ok := b.root.(leafNode[K, V])
+	return ok && leaf.len() == 0
+}
+
+// minKey returns the smallest key of a non-empty tree.
+func (b *Btree[K, V]) minKey() K {
+	n := b.root
+	for inner, ok := n.(*innerNode[K, V]); ok; inner, ok = n.(*innerNode[K, V]) {
+		n = inner.children[0]
+	}
+	return n.(leafNode[K, V]).keyAt(0)
+}
+
+// maxKey returns the largest key of a non-empty tree.
+func (b *Btree[K, V]) maxKey() K {
+	n := b.root
+	for inner, ok := n.(*innerNode[K, V]); ok; inner, ok = n.(*innerNode[K, V]) {
+		n = inner.children[len(inner.children)-1]
+	}
+	leaf := n.(leafNode[K, V])
+	return leaf.keyAt(leaf.len() - 1)
+}
+
+// adopt sets the parent pointers of the children of the inner node that the tree owns, and recomputes its summaries,
+// after its children changed.
+func (b *Btree[K, V]) adopt(n *innerNode[K, V]) {
+	for _, c := range n.children {
+		if ownerOf(c) == b.owner {
+			c.setParent(n)
+		}
+	}
+	b.resummarize(n)
+}
+
+// newLeafOf returns a new leaf with the pairs from, to of the leaf src.
+func (b *Btree[K, V]) newLeafOf(src leafNode[K, V], from, to int) leafNode[K, V] {
+	leaf := b.newLeafNode()
+	for i := from; i < to; i++ {
+		leaf.appendPairs(pair[K, V]{key: src.keyAt(i), value: src.valueAt(i)})
+	}
+	return leaf
+}
+
+// splitNode splits the sub-tree of height h into the pieces with the keys smaller than the key, built by left, and
+// with the rest of the keys, built by right.
+func splitNode[K cmp.Ordered, V any](left, right *Btree[K, V], n node[K, V], h int, key K) (node[K, V], int, node[K, V], int) {
+	switch t := n.(type) {
+	case leafNode[K, V]:
+		t.countAccess()
+		i := t.bisect(key)
+		if i == -1 {
+			i = t.len()
+		}
+		switch i {
+		case 0:
+			return nil, 0, n, 0
+		case t.len():
+			return n, 0, nil, 0
+		}
+		return left.newLeafOf(t, 0, i), 0, right.newLeafOf(t, i, t.len()), 0
+	case *innerNode[K, V]:
+		t.countAccess()
+		i := t.childIndex(key)
+		cl, clh, cr, crh := splitNode(left, right, t.children[i], h-1, key)
+		if cl == nil && i == 0 {
+			return nil, 0, n, h
+		}
+		if cr == nil && i == len(t.children)-1 {
+			return n, h, nil, 0
+		}
+		l, lh := cl, clh
+		if i > 0 {
+			fringe, fringeHeight := left.fringe(t, 0, i, h)
+			l, lh = left.concat(fringe, fringeHeight, t.keys[i-1], cl, clh)
+		}
+		r, rh := cr, crh
+		if i < len(t.keys) {
+			fringe, fringeHeight := right.fringe(t, i+1, len(t.children), h)
+			r, rh = right.concat(cr, crh, t.keys[i], fringe, fringeHeight)
+		}
+		return l, lh, r, rh
+	}
+	return nil, 0, nil, 0
+}
+
+// fringe returns the piece of the children from, to to of the inner node of height h.
+func (b *Btree[K, V]) fringe(n *innerNode[K, V], from, to, h int) (node[K, V], int) {
+	switch to - from {
+	case 0:
+		return nil, 0
+	case 1:
+		return n.children[from], h - 1
+	}
+	f := b.newInnerNode()
+	f.children = append(f.children, n.children[from:to]...)
+	f.keys = append(f.keys, n.keys[from:to-1]...)
+	b.adopt(f)
+	return f, h
+}
+
+// concat returns the piece with the pairs of the pieces l and r, of heights hl and hr. The keys of l are smaller than
+// the separator, and the keys of r are not.
+func (b *Btree[K, V]) concat(l node[K, V], hl int, separator K, r node[K, V], hr int) (node[K, V], int) {
+	if l == nil {
+		return r, hr
+	}
+	if r == nil {
+		return l, hl
+	}
+	n1, n2, separator := b.concatRec(l, hl, separator, r, hr)
+	if n2 == nil {
+		return n1, max(hl, hr)
+	}
+	root := b.newInnerNode()
+	root.children = append(root.children, n1, n2)
+	root.keys = append(root.keys, separator)
+	b.adopt(root)
+	return root, max(hl, hr) + 1
+}
+
+// concatRec descends the right edge of l or the left edge of r, whichever is higher, to the level of the other piece,
+// and glues it there. It returns one node, or two nodes and their separator, if the top node split.
+func (b *Btree[K, V]) concatRec(l node[K, V], hl int, separator K, r node[K, V], hr int) (node[K, V], node[K, V], K) {
+	switch {
+	case hl > hr:
+		x := b.mutable(l, nil).(*innerNode[K, V])
+		last := len(x.children) - 1
+		n1, n2, s := b.concatRec(x.children[last], hl-1, separator, r, hr)
+		x.children[last] = n1
+		if n2 != nil {
+			x.children = append(x.children, n2)
+			x.keys = append(x.keys, s)
+		}
+		return b.settle(x)
+	case hl < hr:
+		x := b.mutable(r, nil).(*innerNode[K, V])
+		n1, n2, s := b.concatRec(l, hl, separator, x.children[0], hr-1)
+		x.children[0] = n1
+		if n2 != nil {
+			x.children = slices.Insert(x.children, 1, n2)
+			x.keys = slices.Insert(x.keys, 0, s)
+		}
+		return b.settle(x)
+	}
+	return b.fixPair(l, separator, r)
+}
+
+// settle adopts the children of the node, and splits the node if it overflows.
+func (b *Btree[K, V]) settle(n *innerNode[K, V]) (node[K, V], node[K, V], K) {
+	b.adopt(n)
+	if !n.isOverflow(b.order) {
+		var zero K
+		return n, nil, zero
+	}
+	if b.rebalanceCounter != nil {
+		b.rebalanceCounter()
+	}
+	left, right, median := n.splitAroundMedian(b.allocator)
+	b.splitSummaries(n, left, right)
+	return left, right, median
+}
+
+// fixPair returns the neighbouring nodes l and r of the same height, separated by the separator, so that both are at
+// least half full. If they fit into one node, it returns their merge, otherwise it splits the merge evenly, unless
+// they are both at least half full already. The merge, and the split of the merge, count as one rebalance.
+func (b *Btree[K, V]) fixPair(l node[K, V], separator K, r node[K, V]) (node[K, V], node[K, V], K) {
+	var zero K
+	if ll, ok := l.(leafNode[K, V]); ok {
+		rl := r.(leafNode[K, V])
+		total := ll.len() + rl.len()
+		if total > b.leafOrder && min(ll.len(), rl.len()) >= minOccupancy(b.leafOrder) {
+			return l, r, separator
+		}
+		if b.rebalanceCounter != nil {
+			b.rebalanceCounter()
+		}
+		merged := b.newLeafOf(ll, 0, ll.len())
+		for i := range rl.len() {
+			merged.appendPairs(pair[K, V]{key: rl.keyAt(i), value: rl.valueAt(i)})
+		}
+		if total <= b.leafOrder {
+			return merged, nil, zero
+		}
+		left, right, median := merged.splitAroundMedian(b.allocator)
+		return left, right, median
+	}
+	li, ri := l.(*innerNode[K, V]), r.(*innerNode[K, V])
+	total := len(li.children) + len(ri.children)
+	if total > b.order && min(len(li.children), len(ri.children)) >= minOccupancy(b.order) {
+		return l, r, separator
+	}
+	if b.rebalanceCounter != nil {
+		b.rebalanceCounter()
+	}
+	merged := b.newInnerNode()
+	merged.children = append(append(merged.children, li.children...), ri.children...)
+	merged.keys = append(append(append(merged.keys, li.keys...), separator), ri.keys...)
+	b.adopt(merged)
+	if total <= b.order {
+		return merged, nil, zero
+	}
+	left, right, median := merged.splitAroundMedian(b.allocator)
+	b.splitSummaries(merged, left, right)
+	return left, right, median
+}
@@ -0,0 +1,124 @@
+package btree
+
+import "slices"
+
+// The summaries of the sub-trees, the counts of WithSubtreeCounts and the aggregates of NewWithMonoid, are kept per
+// child of the inner nodes, in the nodes behind a pointer, so a descent reads them with the node and the nodes of the
+// trees without them grow by a word only. A change of a leaf changes the summaries of all its ancestors, which are
+// found by the parent pointers.
+
+// childSummaries are the counts and the aggregates of the children of an inner node.
+type childSummaries[V any] struct {
+	// counts[i] is the number of the pairs in the sub-tree of child i, nil unless WithSubtreeCounts is used.
+	counts []int
+	// aggregates[i] is the aggregate of the values in the sub-tree of child i, nil unless the tree is created with
+	// NewWithMonoid.
+	aggregates []V
+}
+
+func (s *childSummaries[V]) clone() *childSummaries[V] {
+	return &childSummaries[V]{counts: slices.Clone(s.counts), aggregates: slices.Clone(s.aggregates)}
+}
+
+// size returns the number of the pairs in the sub-tree, from the counts if the tree keeps them.
+func (b *Btree[K, V]) size(n node[K, V]) int {
+	n.countAccess()
+	switch t := n.(type) {
+	case *innerNode[K, V]:
+		return b.sizeOfChildren(t, len(t.children))
+	case leafNode[K, V]:
+		return t.len()
+	}
+	return 0
+}
+
+// sizeOfChild returns the number of the pairs in the sub-tree of the i-th child of the inner node.
+func (b *Btree[K, V]) sizeOfChild(n *innerNode[K, V], i int) int {
+	if s := n.summaries; s != nil && s.counts != nil {
+		return s.counts[i]
+	}
+	return b.size(n.children[i])
+}
+
+// sizeOfChildren returns the number of the pairs in the sub-trees of the first i children of the inner node.
+func (b *Btree[K, V]) sizeOfChildren(n *innerNode[K, V], i int) int {
+	size := 0
+	for j := range i {
+		size += b.sizeOfChild(n, j)
+	}
+	return size
+}
+
+// resummarize recomputes the counts and the aggregates of the inner node, if the tree keeps them, from its children.
+func (b *Btree[K, V]) resummarize(n *innerNode[K, V]) {
+	if !b.subtreeCounts && b.monoid == nil {
+		return
+	}
+	s := &childSummaries[V]{}
+	if b.subtreeCounts {
+		s.counts = make([]int, 0, len(n.children))
+		for _, child := range n.children {
+			s.counts = append(s.counts, b.size(child))
+		}
+	}
+	if b.monoid != nil {
+		s.aggregates = make([]V, 0, len(n.children))
+		for _, child := range n.children {
+			s.aggregates = append(s.aggregates, b.aggregate(child))
+		}
+	}
+	n.summaries = s
+}
+
+// updateSummaries updates the counts and the aggregates of the ancestors of the node after the node changed, from the
+// bottom up.
+func (b *Btree[K, V]) updateSummaries(n node[K, V]) {
+	if !b.subtreeCounts && b.monoid == nil {
+		return
+	}
+	for parent := n.getParent(); parent != nil; n, parent = parent, parent.getParent() {
+		s := parent.summaries
+		i := slices.Index(parent.children, n)
+		if s.counts != nil {
+			s.counts[i] = b.size(n)
+		}
+		if s.aggregates != nil {
+			s.aggregates[i] = b.aggregate(n)
+		}
+	}
+}
+
+// expandSummaries replaces the summaries of the child of the inner node split into left and right, its neighbouring
+// children now, with their summaries.
+func (b *Btree[K, V]) expandSummaries(n *innerNode[K, V], left, right node[K, V]) {
+	s := n.summaries
+	if s == nil {
+		return
+	}
+	i := slices.Index(n.children, left)
+	if s.counts != nil {
+		s.counts = slices.Insert(s.counts, i+1, b.size(right))
+		s.counts[i] = b.size(left)
+	}
+	if s.aggregates != nil {
+		s.aggregates = slices.Insert(s.aggregates, i+1, b.aggregate(right))
+		s.aggregates[i] = b.aggregate(left)
+	}
+}
+
+// splitSummaries moves the summaries of the inner node to left and right, its halves after splitAroundMedian.
+func (b *Btree[K, V]) splitSummaries(n, left, right *innerNode[K, V]) {
+	s := n.summaries
+	if s == nil {
+		return
+	}
+	k := len(left.children)
+	l, r := &childSummaries[V]{}, &childSummaries[V]{}
+	if s.counts != nil {
+		l.counts, r.counts = slices.Clone(s.counts[:k]), slices.Clone(s.counts[k:])
+	}
+	if s.aggregates != nil {
+		l.aggregates, r.aggregates = slices.Clone(s.aggregates[:k]), slices.Clone(s.aggregates[k:])
+	}
+	left.summaries, right.summaries = l, r
+}
@@ -2,30 +2,83 @@ package main
 
 import (
 	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/cli/internal/cliflags"
+	"btree-cache-benchmark/orderedmap"
 	"btree-cache-benchmark/utils"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"slices"
+	"strings"
 )
 
 func main() {
 	flagN := 0
 	flagShuffle := false
 	flagRandom := false
-	flagOrder := 2
+	flagOrder := ""
+	flagNodeCacheLines := 0
+	flagImpl := ""
+	flagLookups := false
+	flagFrozen := ""
+	flagCacheLines := false
+	flagAllocOrder := ""
 	flag.IntVar(&flagN, "n", 1000000, "number of values in the sequence")
 	flag.BoolVar(&flagShuffle, "shuffle", false, "shuffle, can be used to shuffle sequence of N values")
 	flag.BoolVar(&flagRandom, "random", false, "random integers")
-	flag.IntVar(&flagOrder, "order", 2, "order of btree")
+	flag.StringVar(&flagOrder, "order", "2", "order of btree, or auto to choose it from the key and value sizes")
+	flag.IntVar(&flagNodeCacheLines, "node-cache-lines", 4, "cache lines per node for -order=auto")
+	flag.StringVar(&flagImpl, "impl", "btree", "implementation, one of: "+strings.Join(orderedmap.Names(), ", "))
+	flag.BoolVar(&flagLookups, "lookups", false, "histogram of finding all the values in shuffled order after the inserts, instead of the inserts")
+	flag.StringVar(&flagFrozen, "frozen", "", "freeze the btree with given layout (bfs, veb or eytzinger) before the lookups, implies -lookups")
+	flag.BoolVar(&flagCacheLines, "cache-lines", false, "count accesses per 64 byte cache line of the node address instead of per node, to compare memory layouts")
+	flag.StringVar(&flagAllocOrder, "alloc-order", "natural", cliflags.AllocOrderUsage)
 	flag.Parse()
+	order, opts, err := cliflags.ParseOrder(flagOrder, flagNodeCacheLines)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	allocOpts, err := cliflags.ParseAllocOrder(flagAllocOrder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts = append(opts, allocOpts...)
+	var frozenLayout btree.FrozenLayout
+	if flagFrozen != "" {
+		var err error
+		if frozenLayout, err = btree.ParseFrozenLayout(flagFrozen); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		flagLookups = true
+	}
 	ac := cacheAccessCounter{
 		lastAccess: make(map[any]int),
 		hist:       make(map[int]int),
+		cacheLines: flagCacheLines,
+	}
+	newMap, err := orderedmap.Lookup(flagImpl)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	b := btree.New[int, int](flagOrder)
-	b.SetAccessCounter(ac.count)
+	m := newMap(orderedmap.Config{Order: order, BtreeOptions: opts})
+	counted, ok := m.(orderedmap.AccessCounted)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "-impl %s does not count node accesses\n", flagImpl)
+		os.Exit(1)
+	}
+	counted.SetAccessCounter(ac.count)
+	b, isBtree := m.(*btree.Btree[int, int])
+	if !isBtree && (flagAllocOrder != "natural" || flagFrozen != "") {
+		fmt.Fprintf(os.Stderr, "-alloc-order and -frozen need a btree implementation, not %s\n", flagImpl)
+		os.Exit(1)
+	}
+	find := m.Find
 	var values []int
 	summary := "#"
 	summary += fmt.Sprint(" n=", flagN)
@@ -41,8 +94,29 @@ func main() {
 		summary += " shuffled"
 		utils.Shuffle(values)
 	}
+	summary += " impl=" + flagImpl
 	for _, v := range values {
-		b.Insert(v, v)
+		m.Insert(v, v)
+	}
+	if flagAllocOrder == "dfs" {
+		b.Relayout(btree.LayoutDFS)
+	}
+	summary += " alloc-order=" + flagAllocOrder
+	if flagCacheLines {
+		summary += " cache-lines"
+	}
+	if flagFrozen != "" {
+		summary += " frozen=" + flagFrozen
+		find = b.Freeze(frozenLayout).Find
+	}
+	if flagLookups {
+		summary += " lookups"
+		lookups := slices.Clone(values)
+		utils.Shuffle(lookups)
+		ac.reset()
+		for _, v := range lookups {
+			find(v)
+		}
 	}
 	fmt.Fprintln(os.Stderr, summary)
 	ac.writeHistogram(os.Stdout)
@@ -52,10 +126,17 @@ type cacheAccessCounter struct {
 	ts         int
 	lastAccess map[any]int
 	hist       map[int]int
+	// cacheLines makes the counter track the cache line at the start of the node instead of the node. Nodes are
+	// pointers, and Go does not move heap objects, so the address is stable. Nodes that are not pointers, like the
+	// keys of hashmap, are tracked as they are.
+	cacheLines bool
 }
 
 func (c *cacheAccessCounter) count(n any) {
 	c.ts++
+	if v := reflect.ValueOf(n); c.cacheLines && v.Kind() == reflect.Pointer {
+		n = v.Pointer() / btree.CacheLineSize
+	}
 	if prevTs, ok := c.lastAccess[n]; ok {
 		dt := c.ts - prevTs
 		c.hist[dt] = c.hist[dt] + 1
@@ -65,6 +146,13 @@ func (c *cacheAccessCounter) count(n any) {
 	c.lastAccess[n] = c.ts
 }
 
+// reset forgets all the accesses so far, to only histogram the accesses that follow.
+func (c *cacheAccessCounter) reset() {
+	c.ts = 0
+	clear(c.lastAccess)
+	clear(c.hist)
+}
+
 func (c *cacheAccessCounter) writeHistogram(w io.Writer) {
 	timestamps := []int{}
 	for ts := range c.hist {
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/cli/internal/cliflags"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// btree_repair reads a tree printed by Btree.Print from the standard input, repairs it with Btree.Repair, and prints
+// the repaired tree to the standard output. The report of the repair goes to the standard error.
+func main() {
+	flagOrder := ""
+	flagNodeCacheLines := 0
+	flag.StringVar(&flagOrder, "order", "2", "order of the printed btree, or auto to choose it from the key and value sizes")
+	flag.IntVar(&flagNodeCacheLines, "node-cache-lines", 4, "cache lines per node for -order=auto")
+	flag.Parse()
+	order, opts, err := cliflags.ParseOrder(flagOrder, flagNodeCacheLines)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	b, err := btree.ReadPrinted(os.Stdin, order, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	report := b.Repair()
+	for _, v := range report.Violations {
+		fmt.Fprintf(os.Stderr, "# violation: %v\n", v)
+	}
+	fmt.Fprintf(os.Stderr, "# violations=%d pairs=%d dropped=%d moved=%d shared-nodes=%d\n", len(report.Violations), b.Len(), len(report.Dropped), len(report.Moved), report.SharedNodes)
+	b.Print(os.Stdout)
+}
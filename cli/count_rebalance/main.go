@@ -1,25 +1,63 @@
 package main
 
 import (
+	"btree-cache-benchmark/baseline/lsm"
 	"btree-cache-benchmark/btree"
+	"btree-cache-benchmark/cli/internal/cliflags"
+	"btree-cache-benchmark/orderedmap"
 	"btree-cache-benchmark/utils"
 	"flag"
 	"fmt"
+	"os"
+	"runtime"
+	"strings"
 )
 
 func main() {
 	flagN := 0
 	flagShuffle := false
 	flagRandom := false
-	flagOrder := 2
+	flagOrder := ""
+	flagNodeCacheLines := 0
+	flagImpl := ""
+	flagAllocOrder := ""
 	flag.IntVar(&flagN, "n", 1000000, "number of values in the sequence")
 	flag.BoolVar(&flagShuffle, "shuffle", false, "shuffle, can be used to shuffle sequence of N values")
 	flag.BoolVar(&flagRandom, "random", false, "random integers")
-	flag.IntVar(&flagOrder, "order", 2, "order of btree")
+	flag.StringVar(&flagOrder, "order", "2", "order of btree, or auto to choose it from the key and value sizes")
+	flag.IntVar(&flagNodeCacheLines, "node-cache-lines", 4, "cache lines per node for -order=auto")
+	flag.StringVar(&flagImpl, "impl", "btree", "implementation, one of: "+strings.Join(orderedmap.Names(), ", ")+". For lsm, the flushes and the compactions are counted, and the write amplification is reported. The implementations with the same algorithm, like btree and parentless, re-balance the same, so the memory of the inserts is reported too")
+	flag.StringVar(&flagAllocOrder, "alloc-order", "natural", cliflags.AllocOrderUsage+". It does not change the shape of the tree, so neither the re-balances, only the memory")
 	flag.Parse()
+	order, opts, err := cliflags.ParseOrder(flagOrder, flagNodeCacheLines)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	allocOpts, err := cliflags.ParseAllocOrder(flagAllocOrder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts = append(opts, allocOpts...)
+	newMap, err := orderedmap.Lookup(flagImpl)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	m := newMap(orderedmap.Config{Order: order, BtreeOptions: opts})
+	counted, ok := m.(orderedmap.RebalanceCounted)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "-impl %s does not count re-balances\n", flagImpl)
+		os.Exit(1)
+	}
+	b, isBtree := m.(*btree.Btree[int, int])
+	if !isBtree && flagAllocOrder != "natural" {
+		fmt.Fprintf(os.Stderr, "-alloc-order needs a btree implementation, not %s\n", flagImpl)
+		os.Exit(1)
+	}
 	rc := counter{}
-	b := btree.New[int, int](flagOrder)
-	b.SetRebalanceCounter(rc.count)
+	counted.SetRebalanceCounter(rc.count)
 	var values []int
 	summary := ""
 
@@ -34,10 +72,29 @@ func main() {
 		summary = "shuffled"
 		utils.Shuffle(values)
 	}
+	before := memStats()
 	for _, v := range values {
-		b.Insert(v, v)
+		m.Insert(v, v)
 	}
-	fmt.Printf("%s\t%d\t%d\t%d\n", summary, flagOrder, flagN, rc.c)
+	if flagAllocOrder == "dfs" {
+		b.Relayout(btree.LayoutDFS)
+	}
+	after := memStats()
+	runtime.KeepAlive(m)
+	fmt.Printf("%s\t%d\t%d\t%d\n", summary, order, flagN, rc.c)
+	fmt.Fprintf(os.Stderr, "# alloc-order=%s allocs=%d alloc-bytes=%d live-bytes=%d\n", flagAllocOrder, after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc, int64(after.HeapAlloc)-int64(before.HeapAlloc))
+	if l, ok := m.(*lsm.LSM[int, int]); ok {
+		s := l.Stats()
+		fmt.Fprintf(os.Stderr, "# write-amplification=%.2f runs=%d levels=%d\n", s.WriteAmplification(), s.Runs, s.Levels)
+	}
+}
+
+// memStats returns the memory statistics after a garbage collection, so HeapAlloc is the live memory.
+func memStats() runtime.MemStats {
+	runtime.GC()
+	var s runtime.MemStats
+	runtime.ReadMemStats(&s)
+	return s
 }
 
 type counter struct {
@@ -0,0 +1,35 @@
+// Package cliflags parses the flags shared by the CLIs.
+package cliflags
+
+import (
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ParseOrder parses -order flag. For -order=auto, it returns the option to set the leaf order, and reports the chosen
+// orders and the node sizes.
+func ParseOrder(s string, nodeCacheLines int) (int, []btree.Option, error) {
+	if s != "auto" {
+		order, err := strconv.Atoi(s)
+		return order, nil, err
+	}
+	r := btree.AutoOrder[int, int](nodeCacheLines)
+	fmt.Fprintf(os.Stderr, "# auto order=%d leaf-order=%d inner-node-bytes=%d leaf-node-bytes=%d\n", r.Order, r.LeafOrder, r.InnerNodeBytes, r.LeafNodeBytes)
+	return r.Order, r.Options(), nil
+}
+
+// AllocOrderUsage is the usage of -alloc-order flag.
+const AllocOrderUsage = "placement of nodes of btree implementations in memory: natural (in order of creation), random (preallocated and handed out in random order) or dfs (relaid out in DFS order after the inserts)"
+
+// ParseAllocOrder parses -alloc-order flag, and returns the options it needs. The caller relays out the tree for dfs.
+func ParseAllocOrder(s string) ([]btree.Option, error) {
+	switch s {
+	case "natural", "dfs":
+		return nil, nil
+	case "random":
+		return []btree.Option{btree.WithShuffledAllocation(0)}, nil
+	}
+	return nil, fmt.Errorf("unknown -alloc-order %q", s)
+}
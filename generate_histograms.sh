@@ -5,4 +5,9 @@ order=5
 bin/btree_hist -order $order > out/hist_m${order}.txt
 #bin/btree_hist -order $order -random > out/hist_m${order}_rand.txt
 bin/btree_hist -order $order -shuffle > out/hist_m${order}_shuff.txt
-
+bin/btree_hist -order $order -impl parentless > out/hist_m${order}_parentless.txt
+bin/btree_hist -order $order -shuffle -impl parentless > out/hist_m${order}_shuff_parentless.txt
+bin/btree_hist -order $order -shuffle -lookups -cache-lines > out/hist_m${order}_shuff_lookups.txt
+for layout in bfs veb eytzinger; do
+	bin/btree_hist -order $order -shuffle -frozen $layout -cache-lines > out/hist_m${order}_shuff_frozen_${layout}.txt
+done
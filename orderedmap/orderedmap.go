@@ -0,0 +1,40 @@
+// Package orderedmap is the interface of the ordered maps compared in this repository, and the registry of the
+// implementations by name, so the CLIs and the benchmarks can swap the implementations.
+package orderedmap
+
+import "cmp"
+
+// OrderedMap is implemented by all the compared structures. The capabilities that only some of them have are in
+// separate interfaces, to be checked with a type assertion.
+type OrderedMap[K cmp.Ordered, V any] interface {
+	// Insert replaces the value if the key is already present, so a map has at most one value per key.
+	Insert(key K, value V)
+	Find(key K) (V, bool)
+}
+
+// Deleter is an OrderedMap that supports deletes. Delete returns false if there was no such key.
+type Deleter[K cmp.Ordered] interface {
+	Delete(key K) bool
+}
+
+// Scanner is an OrderedMap that supports range scans. Scan calls fun for the keys in [lo, hi) range in ascending
+// order, until fun returns false.
+type Scanner[K cmp.Ordered, V any] interface {
+	Scan(lo, hi K, fun func(key K, value V) bool)
+}
+
+// AccessCounted is an OrderedMap instrumented to report every access to a node. SetAccessCounter must be called right
+// after creating the map.
+type AccessCounted interface {
+	SetAccessCounter(ac func(n any))
+}
+
+// RebalanceCounted is an OrderedMap instrumented to report every re-balance of the nodes.
+type RebalanceCounted interface {
+	SetRebalanceCounter(rc func())
+}
+
+// IntegrityChecker is an OrderedMap that can check its invariants.
+type IntegrityChecker interface {
+	IntegrityCheck() error
+}
@@ -0,0 +1,85 @@
+package orderedmap_test
+
+import (
+	"btree-cache-benchmark/btree/btreetest"
+	"btree-cache-benchmark/orderedmap"
+	"btree-cache-benchmark/utils"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+const (
+	nValues = btreetest.BenchmarkN
+)
+
+var orders = btreetest.BenchmarkOrders
+
+// sequenceTypes include the random keys, which are sparse unlike the ranges, for the implementations sensitive to the
+// distribution of the key bits, like art.
+var sequenceTypes = btreetest.SequenceTypes
+
+// BenchmarkInsert runs the inserts of btree package benchmarks for all the registered implementations.
+func BenchmarkInsert(t *testing.B) {
+	for _, impl := range orderedmap.Names() {
+		t.Run("impl:"+impl, func(b *testing.B) {
+			btreetest.RunInsertBenchmarks(b, factory(b, impl), orders, sequenceTypes)
+		})
+	}
+}
+
+func BenchmarkFind(t *testing.B) {
+	for _, impl := range orderedmap.Names() {
+		t.Run("impl:"+impl, func(b *testing.B) {
+			btreetest.RunFindBenchmarks(b, factory(b, impl), orders, sequenceTypes)
+		})
+	}
+}
+
+// BenchmarkFindAccesses reports the accesses per lookup next to the lookup time, for the implementations counting
+// them. The accesses are to the nodes, or to the array elements for the implementations without nodes.
+func BenchmarkFindAccesses(t *testing.B) {
+	for _, impl := range orderedmap.Names() {
+		for _, order := range orders {
+			for _, s := range sequenceTypes {
+				name := fmt.Sprintf("impl:%s/n:%d_order:%d_seq:%s", impl, nValues, order, s)
+				sequence := btreetest.Sequence(nValues, s)
+				m := mustLookup(t, impl)(orderedmap.Config{Order: order})
+				counted, ok := m.(orderedmap.AccessCounted)
+				if !ok {
+					continue
+				}
+				accesses := 0
+				counted.SetAccessCounter(func(n any) { accesses++ })
+				for _, value := range sequence {
+					m.Insert(value, value)
+				}
+				lookups := slices.Clone(sequence)
+				utils.Shuffle(lookups)
+				t.Run(name, func(b *testing.B) {
+					accesses = 0
+					for range b.N {
+						for _, value := range lookups {
+							m.Find(value)
+						}
+					}
+					b.ReportMetric(float64(accesses)/float64(b.N*len(lookups)), "accesses/find")
+				})
+			}
+		}
+	}
+}
+
+func mustLookup(t *testing.B, impl string) orderedmap.Factory {
+	f, err := orderedmap.Lookup(impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// factory adapts the registered implementation to btreetest.
+func factory(t *testing.B, impl string) btreetest.Factory {
+	f := mustLookup(t, impl)
+	return func(order int) btreetest.Tree { return f(orderedmap.Config{Order: order}) }
+}
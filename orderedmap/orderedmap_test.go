@@ -0,0 +1,37 @@
+package orderedmap_test
+
+import (
+	"btree-cache-benchmark/btree/btreetest"
+	"btree-cache-benchmark/orderedmap"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisteredImplementations(t *testing.T) {
+	for _, name := range orderedmap.Names() {
+		t.Run(name, func(t *testing.T) {
+			f, err := orderedmap.Lookup(name)
+			assert.NoError(t, err)
+			factory := func(order int) btreetest.Tree { return f(orderedmap.Config{Order: order}) }
+			btreetest.RunConformance(t, factory)
+			btreetest.RunModel(t, factory, fmt.Sprintf("func(order int) orderedmap.OrderedMap[int, int] { f, _ := orderedmap.Lookup(%q); return f(orderedmap.Config{Order: order}) }(%%d)", name))
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	f, err := orderedmap.Lookup("btree")
+	assert.NoError(t, err)
+	m := f(orderedmap.Config{Order: 3})
+	assert.Implements(t, (*orderedmap.Scanner[int, int])(nil), m)
+	assert.Implements(t, (*orderedmap.AccessCounted)(nil), m)
+	assert.Implements(t, (*orderedmap.RebalanceCounted)(nil), m)
+	assert.Implements(t, (*orderedmap.IntegrityChecker)(nil), m)
+}
+
+func TestLookupUnknown(t *testing.T) {
+	_, err := orderedmap.Lookup("no such implementation")
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package orderedmap
+
+import (
+	"btree-cache-benchmark/baseline/art"
+	"btree-cache-benchmark/baseline/hashmap"
+	"btree-cache-benchmark/baseline/learned"
+	"btree-cache-benchmark/baseline/lsm"
+	"btree-cache-benchmark/baseline/rbtree"
+	"btree-cache-benchmark/baseline/skiplist"
+	"btree-cache-benchmark/baseline/sortedslice"
+	"btree-cache-benchmark/btree"
+	"fmt"
+	"slices"
+)
+
+// Config is what a Factory needs to create an implementation. Each implementation uses only the fields relevant for it.
+type Config struct {
+	// Order is the order of the B-trees.
+	Order int
+	// BtreeOptions are the options of the implementations using btree.New.
+	BtreeOptions []btree.Option
+}
+
+// Factory creates a new empty OrderedMap. The registry is for int keys and values, which are used in all the
+// benchmarks.
+type Factory func(c Config) OrderedMap[int, int]
+
+var registry = map[string]Factory{}
+
+// Register adds the implementation to the registry. It panics if the name is already registered.
+func Register(name string, f Factory) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("implementation %q already registered", name))
+	}
+	registry[name] = f
+}
+
+func Lookup(name string) (Factory, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown implementation %q, known are: %v", name, Names())
+	}
+	return f, nil
+}
+
+// Names returns the sorted names of all the registered implementations.
+func Names() []string {
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func init() {
+	Register("btree", func(c Config) OrderedMap[int, int] {
+		return btree.New[int, int](c.Order, c.BtreeOptions...)
+	})
+	Register("btree-soa", func(c Config) OrderedMap[int, int] {
+		opts := append(slices.Clone(c.BtreeOptions), btree.WithLeafLayout(btree.LeafLayoutSoA))
+		return btree.New[int, int](c.Order, opts...)
+	})
+	Register("flat", func(c Config) OrderedMap[int, int] {
+		return btree.NewFlat[int, int](c.Order)
+	})
+	Register("parentless", func(c Config) OrderedMap[int, int] {
+		return btree.NewParentless[int, int](c.Order)
+	})
+	// The inline variants have fixed order, so they ignore Config.Order.
+	Register("inline4", func(c Config) OrderedMap[int, int] { return btree.NewInline4[int, int]() })
+	Register("inline8", func(c Config) OrderedMap[int, int] { return btree.NewInline8[int, int]() })
+	Register("inline16", func(c Config) OrderedMap[int, int] { return btree.NewInline16[int, int]() })
+	Register("inline32", func(c Config) OrderedMap[int, int] { return btree.NewInline32[int, int]() })
+	Register("inline64", func(c Config) OrderedMap[int, int] { return btree.NewInline64[int, int]() })
+	// Baselines to compare the B-trees with.
+	Register("sortedslice", func(c Config) OrderedMap[int, int] { return sortedslice.New[int, int]() })
+	Register("skiplist", func(c Config) OrderedMap[int, int] { return skiplist.New[int, int]() })
+	Register("rbtree", func(c Config) OrderedMap[int, int] { return rbtree.New[int, int]() })
+	Register("hashmap", func(c Config) OrderedMap[int, int] { return hashmap.New[int, int]() })
+	Register("art", func(c Config) OrderedMap[int, int] { return art.New[int, int]() })
+	Register("learned", func(c Config) OrderedMap[int, int] { return learned.New[int, int](learned.DefaultEpsilon) })
+	// The LSM variants use Config for the memtable.
+	Register("lsm", func(c Config) OrderedMap[int, int] {
+		return lsm.New[int, int](c.Order, lsm.WithBtreeOptions(c.BtreeOptions...))
+	})
+	Register("lsm-tiered", func(c Config) OrderedMap[int, int] {
+		return lsm.New[int, int](c.Order, lsm.WithCompactionPolicy(lsm.Tiered), lsm.WithBtreeOptions(c.BtreeOptions...))
+	})
+}